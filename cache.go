@@ -1,49 +1,319 @@
 package cache
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/frozzare/go-cache/store"
 )
 
+// IsNotFound returns true if the error is returned for a item
+// that don't exists or has expired in the cache.
+func IsNotFound(err error) bool {
+	return errors.Is(err, store.ErrNotFound)
+}
+
 // Cache represents the cache struct.
 type Cache struct {
-	store store.Store
+	store  store.Store
+	group  *group
+	prefix string
 }
 
 // New with the given options.
 func New(store store.Store) *Cache {
-	return &Cache{store}
+	return &Cache{
+		store: store,
+		group: &group{},
+	}
+}
+
+// Keys will retrieve all keys in the cache that matches the glob
+// pattern, '*' matches any sequence of characters, '?' matches a
+// single character and '[abc]' matches a set of characters.
+func (c *Cache) Keys(pattern string) ([]string, error) {
+	keys := []string{}
+
+	err := c.Scan(literal(pattern), func(key string) bool {
+		if match(pattern, key) {
+			keys = append(keys, key)
+		}
+
+		return true
+	})
+
+	return keys, err
+}
+
+// Len will retrieve the number of items in the cache.
+func (c *Cache) Len() (int, error) {
+	s, ok := c.store.(store.ScanStore)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+
+	if c.prefix == "" {
+		return s.Len()
+	}
+
+	n := 0
+
+	err := c.Scan("", func(string) bool {
+		n++
+		return true
+	})
+
+	return n, err
+}
+
+// Namespace returns a view of the cache where all keys are prefixed
+// with the given prefix. Flush on the returned cache will only remove
+// the items in the namespace, which requires a store that implements
+// store.PrefixStore.
+func (c *Cache) Namespace(prefix string) *Cache {
+	return &Cache{
+		store:  c.store,
+		group:  c.group,
+		prefix: c.prefix + prefix + ":",
+	}
+}
+
+// key returns the key with the namespace prefix.
+func (c *Cache) key(key string) string {
+	return c.prefix + key
+}
+
+// duration returns the first expiration or zero if there is none.
+func duration(expiration []time.Duration) time.Duration {
+	if len(expiration) > 0 {
+		return expiration[0]
+	}
+
+	return 0
+}
+
+// conditional returns the store as a conditional store.
+func (c *Cache) conditional() (store.ConditionalStore, error) {
+	s, ok := c.store.(store.ConditionalStore)
+	if !ok {
+		return nil, store.ErrNotSupported
+	}
+
+	return s, nil
+}
+
+// Add will store a item in the cache only if the key don't exists,
+// true is returned if the item was stored.
+func (c *Cache) Add(key string, value interface{}, expiration ...time.Duration) (bool, error) {
+	s, err := c.conditional()
+	if err != nil {
+		return false, err
+	}
+
+	return s.Add(c.key(key), value, duration(expiration))
+}
+
+// CompareAndSwap will store the new value in the cache only if the
+// current value is equal to the old value, true is returned if the
+// value was stored.
+func (c *Cache) CompareAndSwap(key string, old, new interface{}, expiration ...time.Duration) (bool, error) {
+	s, err := c.conditional()
+	if err != nil {
+		return false, err
+	}
+
+	return s.CompareAndSwap(c.key(key), old, new, duration(expiration))
+}
+
+// Decrement will decrement a number in the cache by one or by
+// the given value and returns the new value.
+func (c *Cache) Decrement(key string, n ...int64) (int64, error) {
+	return c.DecrementContext(context.Background(), key, n...)
 }
 
 // Flush remove all items from the cache.
 func (c *Cache) Flush() error {
-	return c.store.Flush()
+	return c.FlushContext(context.Background())
 }
 
 // Get will retrive a item from the cache.
 func (c *Cache) Get(key string) (interface{}, error) {
-	return c.store.Get(key)
+	return c.GetContext(context.Background(), key)
+}
+
+// GetMulti will retrieve multiple items from the cache, items
+// that don't exists are left out of the result.
+func (c *Cache) GetMulti(keys []string) (map[string]interface{}, error) {
+	items := make(map[string]interface{}, len(keys))
+
+	if s, ok := c.store.(store.BatchStore); ok {
+		prefixed := make([]string, len(keys))
+		for i, key := range keys {
+			prefixed[i] = c.key(key)
+		}
+
+		r, err := s.GetMulti(prefixed)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, v := range r {
+			items[strings.TrimPrefix(key, c.prefix)] = v
+		}
+
+		return items, nil
+	}
+
+	for _, key := range keys {
+		v, err := c.store.Get(c.key(key))
+		if IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		items[key] = v
+	}
+
+	return items, nil
+}
+
+// Increment will increment a number in the cache by one or by
+// the given value and returns the new value.
+func (c *Cache) Increment(key string, n ...int64) (int64, error) {
+	return c.IncrementContext(context.Background(), key, n...)
+}
+
+// Number will retrieve a number from the cache.
+func (c *Cache) Number(key string) (int64, error) {
+	return c.NumberContext(context.Background(), key)
+}
+
+// Persist will remove the expiration of a item in the cache.
+func (c *Cache) Persist(key string) error {
+	s, ok := c.store.(store.TTLStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+
+	return s.Persist(c.key(key))
+}
+
+// Remember will retrieve a item from the cache, if the item don't exists
+// fn is called and the returned value is stored in the cache with the
+// given expiration. Concurrent calls for the same key will wait for the
+// first call to fn and share its result.
+func (c *Cache) Remember(key string, expiration time.Duration, fn store.RememberFunc) (interface{}, error) {
+	return c.RememberContext(context.Background(), key, expiration, fn)
 }
 
 // Remove will remove a item from the cache.
 func (c *Cache) Remove(key string) error {
-	return c.store.Remove(key)
+	return c.RemoveContext(context.Background(), key)
+}
+
+// RemoveMulti will remove multiple items from the cache,
+// keys that don't exists are ignored.
+func (c *Cache) RemoveMulti(keys []string) error {
+	if s, ok := c.store.(store.BatchStore); ok {
+		prefixed := make([]string, len(keys))
+		for i, key := range keys {
+			prefixed[i] = c.key(key)
+		}
+
+		return s.RemoveMulti(prefixed)
+	}
+
+	for _, key := range keys {
+		if err := c.store.Remove(c.key(key)); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replace will store a item in the cache only if the key exists,
+// true is returned if the item was stored.
+func (c *Cache) Replace(key string, value interface{}, expiration ...time.Duration) (bool, error) {
+	s, err := c.conditional()
+	if err != nil {
+		return false, err
+	}
+
+	return s.Replace(c.key(key), value, duration(expiration))
 }
 
 // Result will retrieve a item from the cache and stores the
 // result in the value pointed to by v.
 func (c *Cache) Result(key string, v interface{}) error {
-	return c.store.Result(key, v)
+	return c.ResultContext(context.Background(), key, v)
+}
+
+// Scan will call fn for each key in the cache that starts with
+// the prefix, the scan is stopped when fn returns false. Keys
+// are streamed from the store so fn should not hold on to the
+// keys if the cache is large.
+func (c *Cache) Scan(prefix string, fn func(key string) bool) error {
+	s, ok := c.store.(store.ScanStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+
+	return s.Scan(c.key(prefix), func(key string) bool {
+		return fn(strings.TrimPrefix(key, c.prefix))
+	})
 }
 
 // Set will store a item in the cache.
 func (c *Cache) Set(key string, value interface{}, expiration ...time.Duration) error {
-	e := time.Duration(0)
+	return c.SetContext(context.Background(), key, value, expiration...)
+}
 
-	if len(expiration) > 0 {
-		e = expiration[0]
+// SetMulti will store multiple items in the cache.
+func (c *Cache) SetMulti(items map[string]interface{}, expiration ...time.Duration) error {
+	e := duration(expiration)
+
+	if s, ok := c.store.(store.BatchStore); ok {
+		prefixed := make(map[string]interface{}, len(items))
+		for key, value := range items {
+			prefixed[c.key(key)] = value
+		}
+
+		return s.SetMulti(prefixed, e)
+	}
+
+	for key, value := range items {
+		if err := c.store.Set(c.key(key), value, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Touch will set a new expiration on a item in the cache without
+// rewriting the value, a ttl of zero or less removes the expiration.
+func (c *Cache) Touch(key string, ttl time.Duration) error {
+	s, ok := c.store.(store.TTLStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+
+	return s.Touch(c.key(key), ttl)
+}
+
+// TTL will retrieve the remaining time to live of a item in
+// the cache, zero is returned if the item never expires.
+func (c *Cache) TTL(key string) (time.Duration, error) {
+	s, ok := c.store.(store.TTLStore)
+	if !ok {
+		return 0, store.ErrNotSupported
 	}
 
-	return c.store.Set(key, value, e)
+	return s.TTL(c.key(key))
 }
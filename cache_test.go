@@ -0,0 +1,399 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/frozzare/go-cache/store/bolt"
+	"github.com/frozzare/go-cache/store/memory"
+)
+
+// caches returns a cache for each store that the
+// cache is tested with.
+func caches(t *testing.T) map[string]*Cache {
+	s, err := bolt.NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	return map[string]*Cache{
+		"memory": New(memory.NewStore()),
+		"bolt":   New(s),
+	}
+}
+
+func TestRemember(t *testing.T) {
+	for name, c := range caches(t) {
+		t.Run(name, func(t *testing.T) {
+			v, err := c.Remember("name", 0, func() (interface{}, error) {
+				return "go", nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v != "go" {
+				t.Fatalf("%v does not match the expected value: go", v)
+			}
+
+			v, err = c.Remember("name", 0, func() (interface{}, error) {
+				return "rust", nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v != "go" {
+				t.Fatalf("%v does not match the expected value: go", v)
+			}
+		})
+	}
+}
+
+func TestRememberError(t *testing.T) {
+	c := New(memory.NewStore())
+
+	_, err := c.Remember("name", 0, func() (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if _, err := c.Get("name"); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+func TestRememberConcurrent(t *testing.T) {
+	for name, c := range caches(t) {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			var wg sync.WaitGroup
+
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					v, err := c.Remember("name", 0, func() (interface{}, error) {
+						atomic.AddInt32(&calls, 1)
+						time.Sleep(50 * time.Millisecond)
+						return "go", nil
+					})
+
+					if err != nil {
+						t.Error(err)
+					}
+
+					if v != "go" {
+						t.Errorf("%v does not match the expected value: go", v)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			if n := atomic.LoadInt32(&calls); n != 1 {
+				t.Fatalf("Expected one call, got %d", n)
+			}
+		})
+	}
+}
+
+func TestRememberPanic(t *testing.T) {
+	c := New(memory.NewStore())
+
+	started := make(chan struct{})
+	done := make(chan interface{})
+
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+
+		c.Remember("name", 0, func() (interface{}, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			panic("failed")
+		})
+	}()
+
+	<-started
+
+	_, err := c.Remember("name", 0, func() (interface{}, error) {
+		t.Error("Expected remember func to not be called")
+		return nil, nil
+	})
+
+	if err == nil {
+		t.Fatal("Expected error from the panicking call, got nil")
+	}
+
+	if r := <-done; r != "failed" {
+		t.Fatalf("Expected the panic to be passed on, got %v", r)
+	}
+}
+
+func TestRememberContextWaiter(t *testing.T) {
+	c := New(memory.NewStore())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := c.RememberContext(ctx, "name", 0, func() (interface{}, error) {
+			close(started)
+			<-release
+			return "go", nil
+		})
+
+		done <- err
+	}()
+
+	<-started
+
+	waiter := make(chan interface{}, 1)
+
+	go func() {
+		v, err := c.RememberContext(context.Background(), "name", 0, func() (interface{}, error) {
+			return "rust", nil
+		})
+
+		if err != nil {
+			t.Error(err)
+		}
+
+		waiter <- v
+	}()
+
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	close(release)
+
+	if v := <-waiter; v != "go" {
+		t.Fatalf("Expected go, got %v", v)
+	}
+
+	if v, err := c.Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go to be stored, got %v (%v)", v, err)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	c := New(memory.NewStore())
+
+	if _, err := c.Get("missing"); !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+
+	if IsNotFound(errors.New("failed")) {
+		t.Fatal("Expected false, got true")
+	}
+}
+
+func TestMulti(t *testing.T) {
+	c := New(memory.NewStore())
+
+	items := map[string]interface{}{
+		"a": "go",
+		"b": 1,
+	}
+
+	if err := c.SetMulti(items); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.GetMulti([]string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(r, items) {
+		t.Fatalf("%v does not match the expected value: %v", r, items)
+	}
+
+	if err := c.RemoveMulti([]string{"a", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("a"); !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	c := New(memory.NewStore())
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := c.SetContext(ctx, "name", "go"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.GetContext(ctx, "name"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	cancel()
+
+	if _, err := c.GetContext(ctx, "name"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if err := c.SetContext(ctx, "name", "rust"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	_, err := c.RememberContext(ctx, "other", 0, func() (interface{}, error) {
+		t.Fatal("Expected remember func to not be called")
+		return nil, nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	c := New(memory.NewStore())
+	a := c.Namespace("a")
+	b := c.Namespace("b")
+
+	for _, c := range []*Cache{c, a, b} {
+		if err := c.Set("name", "go"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if v, err := c.Get("a:name"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	r, err := a.GetMulti([]string{"name"})
+	if err != nil || r["name"] != "go" {
+		t.Fatalf("Expected go, got %v (%v)", r, err)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Get("name"); !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+
+	for _, c := range []*Cache{c, b} {
+		if v, err := c.Get("name"); err != nil || v != "go" {
+			t.Fatalf("Expected go, got %v (%v)", v, err)
+		}
+	}
+
+	if v, err := c.Namespace("b").Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+}
+
+func TestConditional(t *testing.T) {
+	c := New(memory.NewStore()).Namespace("cond")
+
+	if ok, err := c.Add("key", 1); err != nil || !ok {
+		t.Fatalf("Expected add to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := c.Add("key", 2); err != nil || ok {
+		t.Fatalf("Expected add to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := c.CompareAndSwap("key", 1, 2); err != nil || !ok {
+		t.Fatalf("Expected compare and swap to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := c.Replace("key", 3); err != nil || !ok {
+		t.Fatalf("Expected replace to succeed, got %v (%v)", ok, err)
+	}
+
+	if v, err := c.Get("key"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %v (%v)", v, err)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	c := New(memory.NewStore())
+
+	if err := c.Set("session", "go", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Touch("session", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := c.TTL("session"); err != nil || ttl <= time.Minute {
+		t.Fatalf("Expected ttl longer than a minute, got %s (%v)", ttl, err)
+	}
+
+	if err := c.Persist("session"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := c.TTL("session"); err != nil || ttl != 0 {
+		t.Fatalf("Expected no ttl, got %s (%v)", ttl, err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	c := New(memory.NewStore())
+	u := c.Namespace("users")
+
+	for _, k := range []string{"1", "2", "10"} {
+		if err := u.Set(k, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Set("other", "go"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := u.Keys("?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(keys)
+
+	if !reflect.DeepEqual(keys, []string{"1", "2"}) {
+		t.Fatalf("%v does not match the expected value: [1 2]", keys)
+	}
+
+	keys, err = c.Keys("users:*")
+	if err != nil || len(keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %v (%v)", keys, err)
+	}
+
+	if n, err := u.Len(); err != nil || n != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", n, err)
+	}
+
+	if n, err := c.Len(); err != nil || n != 4 {
+		t.Fatalf("Expected 4, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// DecrementContext will decrement a number in the cache by one or by
+// the given value with the given context and returns the new value.
+func (c *Cache) DecrementContext(ctx context.Context, key string, n ...int64) (int64, error) {
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.DecrementContext(ctx, c.key(key), n...)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.store.Decrement(c.key(key), n...)
+}
+
+// FlushContext remove all items from the cache with the given context.
+func (c *Cache) FlushContext(ctx context.Context) error {
+	if c.prefix != "" {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if s, ok := c.store.(store.PrefixStore); ok {
+			return s.FlushPrefix(c.prefix)
+		}
+
+		return store.ErrNotSupported
+	}
+
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.FlushContext(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.store.Flush()
+}
+
+// GetContext will retrive a item from the cache with the given context.
+func (c *Cache) GetContext(ctx context.Context, key string) (interface{}, error) {
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.GetContext(ctx, c.key(key))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.store.Get(c.key(key))
+}
+
+// IncrementContext will increment a number in the cache by one or by
+// the given value with the given context and returns the new value.
+func (c *Cache) IncrementContext(ctx context.Context, key string, n ...int64) (int64, error) {
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.IncrementContext(ctx, c.key(key), n...)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.store.Increment(c.key(key), n...)
+}
+
+// NumberContext will retrieve a number from the cache with the given context.
+func (c *Cache) NumberContext(ctx context.Context, key string) (int64, error) {
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.NumberContext(ctx, c.key(key))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.store.Number(c.key(key))
+}
+
+// detached is a context with the values of the parent context
+// that is never done.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
+
+// RememberContext works like Remember but uses the given context when
+// the cache is read and written. Concurrent callers share one call to fn,
+// which is not cancelled when the context of the first caller is done,
+// but each caller only waits for it until its own context is done.
+func (c *Cache) RememberContext(ctx context.Context, key string, expiration time.Duration, fn store.RememberFunc) (interface{}, error) {
+	if v, err := c.GetContext(ctx, key); err == nil || !IsNotFound(err) {
+		return v, err
+	}
+
+	return c.group.doContext(ctx, c.key(key), func() (interface{}, error) {
+		ctx := detached{ctx}
+
+		if v, err := c.GetContext(ctx, key); err == nil || !IsNotFound(err) {
+			return v, err
+		}
+
+		v, err := fn()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.SetContext(ctx, key, v, expiration); err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	})
+}
+
+// RemoveContext will remove a item from the cache with the given context.
+func (c *Cache) RemoveContext(ctx context.Context, key string) error {
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.RemoveContext(ctx, c.key(key))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.store.Remove(c.key(key))
+}
+
+// ResultContext will retrieve a item from the cache with the given
+// context and stores the result in the value pointed to by v.
+func (c *Cache) ResultContext(ctx context.Context, key string, v interface{}) error {
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.ResultContext(ctx, c.key(key), v)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.store.Result(c.key(key), v)
+}
+
+// SetContext will store a item in the cache with the given context.
+func (c *Cache) SetContext(ctx context.Context, key string, value interface{}, expiration ...time.Duration) error {
+	if s, ok := c.store.(store.ContextStore); ok {
+		return s.SetContext(ctx, c.key(key), value, duration(expiration))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.store.Set(c.key(key), value, duration(expiration))
+}
@@ -0,0 +1,112 @@
+package cache
+
+// match reports whether the key matches the glob pattern. It supports
+// the same syntax as redis, '*' matches any sequence of characters, '?'
+// matches a single character, '[abc]', '[a-z]' and '[^a]' matches a set
+// of characters and '\' escapes the next character.
+func match(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 0 {
+				return true
+			}
+
+			for i := 0; i <= len(key); i++ {
+				if match(pattern, key[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+
+			n, ok := matchClass(pattern, key[0])
+			if !ok {
+				return false
+			}
+
+			pattern = pattern[n:]
+			key = key[1:]
+			continue
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+		}
+
+		pattern = pattern[1:]
+		key = key[1:]
+	}
+
+	return len(key) == 0
+}
+
+// matchClass matches the character against the class at the start of
+// the pattern and returns the length of the class in the pattern.
+func matchClass(pattern string, c byte) (int, bool) {
+	i := 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+
+		lo, hi := pattern[i], pattern[i]
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			hi = pattern[i+2]
+			i += 2
+		}
+
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	if i == len(pattern) {
+		// A class without a closing bracket matches nothing.
+		return 0, false
+	}
+
+	return i + 1, matched != negate
+}
+
+// literal returns the part of the pattern before the first special
+// character, all keys matching the pattern starts with it.
+func literal(pattern string) string {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[', '\\':
+			return pattern[:i]
+		}
+	}
+
+	return pattern
+}
@@ -0,0 +1,52 @@
+package cache
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "user:1", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users:1", false},
+		{"user/*", "user/1/2", true},
+		{"*:1", "user:1", true},
+		{"u*r:?", "user:1", true},
+		{"user:?", "user:10", false},
+		{"user:[0-9]", "user:5", true},
+		{"user:[0-9]", "user:a", false},
+		{"user:[^0-9]", "user:a", true},
+		{"user:[abc]", "user:b", true},
+		{"user:[abc", "user:b", false},
+		{`user\*`, "user*", true},
+		{`user\*`, "users", false},
+		{"user", "user", true},
+		{"user", "user:1", false},
+	}
+
+	for _, test := range tests {
+		if got := match(test.pattern, test.key); got != test.want {
+			t.Errorf("match(%q, %q) = %v, want %v", test.pattern, test.key, got, test.want)
+		}
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := map[string]string{
+		"user:*":   "user:",
+		"user:?":   "user:",
+		"user:[a]": "user:",
+		`user\*`:   "user",
+		"user":     "user",
+		"*":        "",
+	}
+
+	for pattern, want := range tests {
+		if got := literal(pattern); got != want {
+			t.Errorf("literal(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// call represents a in-flight or completed call for a key.
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// group makes sure that only one call per key is in-flight at
+// the same time, duplicate callers wait for the first call and
+// share its result.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do executes fn for the given key unless a call for the same key
+// is already in-flight, in that case it waits for that call instead.
+func (g *group) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// If fn panics the waiters gets a error and
+	// the panic is passed on to the caller.
+	defer func() {
+		r := recover()
+		if r != nil {
+			c.val, c.err = nil, fmt.Errorf("cache: call for %s panicked: %v", key, r)
+		}
+
+		c.wg.Done()
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	c.val, c.err = fn()
+
+	return c.val, c.err
+}
+
+// result represents the result of a call that
+// is passed to the caller of doContext.
+type result struct {
+	val   interface{}
+	err   error
+	panic interface{}
+}
+
+// doContext works like do but runs the call in its own goroutine, so
+// a caller stops waiting when its context is done while the call goes
+// on for the other callers. A panic in fn is passed on to the caller
+// if it's still waiting.
+func (g *group) doContext(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	ch := make(chan result, 1)
+
+	go func() {
+		var r result
+
+		defer func() {
+			r.panic = recover()
+			ch <- r
+		}()
+
+		r.val, r.err = g.do(key, fn)
+	}()
+
+	select {
+	case r := <-ch:
+		if r.panic != nil {
+			panic(r.panic)
+		}
+
+		return r.val, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
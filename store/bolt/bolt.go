@@ -1,18 +1,28 @@
 package bolt
 
 import (
-	"fmt"
+	"bytes"
+	"errors"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	boltdb "github.com/boltdb/bolt"
 	"github.com/frozzare/go-cache/store"
 )
 
+// scanBatch is the number of keys that are read in one transaction by Scan.
+const scanBatch = 1000
+
+// sweepBatch is the default number of expired items that
+// are removed in one transaction by DeleteExpired.
+const sweepBatch = 1000
+
 var (
-	bucket    = []byte("store")
-	bucketTTL = []byte("store_ttl")
+	bucket       = []byte("store")
+	bucketTTL    = []byte("store_ttl")
+	bucketExpiry = []byte("store_expiry")
 )
 
 // Options is a alias for bolt options.
@@ -20,120 +30,643 @@ type Options = boltdb.Options
 
 // Store represents the redis cache store.
 type Store struct {
-	db *boltdb.DB
+	db       *boltdb.DB
+	codec    store.Codec
+	perm     os.FileMode
+	opts     *Options
+	interval time.Duration
+	batch    int
+	useBatch bool
+	window   time.Duration
+
+	// mu is only locked for writing when the database
+	// is replaced by Compact.
+	mu   sync.RWMutex
+	stop chan struct{}
+	once sync.Once
+
+	// wmu guards the writes that are waiting to
+	// be written in write-behind mode.
+	wmu     sync.Mutex
+	pending map[string]*write
+	timer   *time.Timer
+	werr    error
 }
 
 // NewStore will create a new redis store with the given options.
-func NewStore(name string, permission os.FileMode, opts *Options) (store.Store, error) {
+func NewStore(name string, permission os.FileMode, opts *Options, options ...Option) (store.Store, error) {
 	db, err := boltdb.Open(name, permission, opts)
 
-	return &Store{
-		db: db,
-	}, err
+	s := &Store{
+		db:      db,
+		perm:    permission,
+		opts:    opts,
+		batch:   sweepBatch,
+		stop:    make(chan struct{}),
+		pending: make(map[string]*write),
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	if err != nil {
+		return s, err
+	}
+
+	if opts == nil || !opts.ReadOnly {
+		if err := s.update(createBuckets); err != nil {
+			return s, err
+		}
+	}
+
+	if s.interval > 0 {
+		go s.janitor()
+	}
+
+	return s, nil
+}
+
+// createBuckets creates the buckets that are used by the store, so
+// the other methods never has to handle buckets that don't exists.
+func createBuckets(tx *boltdb.Tx) error {
+	for _, name := range [][]byte{bucket, bucketTTL} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+
+	return reindex(tx)
+}
+
+// update runs fn in a read-write transaction, after the
+// pending writes has been written in the same transaction.
+func (s *Store) update(fn func(*boltdb.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var writes map[string]*write
+
+	err := s.db.Update(func(tx *boltdb.Tx) error {
+		var err error
+		if writes, err = s.apply(tx); err != nil {
+			return err
+		}
+
+		return fn(tx)
+	})
+
+	if err == nil {
+		s.applied(writes)
+	}
+
+	return err
+}
+
+// view runs fn in a read-only transaction.
+func (s *Store) view(fn func(*boltdb.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.View(fn)
+}
+
+// expired returns true if the expiration stored for the key
+// in the given ttl bucket has passed.
+func expired(b *boltdb.Bucket, key string) (bool, error) {
+	exp := b.Get([]byte(key))
+	if len(exp) == 0 {
+		return false, nil
+	}
+
+	i, err := strconv.ParseInt(string(exp), 10, 64)
+	if err != nil {
+		return false, err
+	}
+
+	return store.Expired(i), nil
+}
+
+// get returns the encoded item if it exists and has not expired.
+func get(tx *boltdb.Tx, key string) ([]byte, error) {
+	if b := tx.Bucket(bucketTTL); b != nil {
+		ok, err := expired(b, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return nil, store.ErrExpired
+		}
+	}
+
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return nil, store.ErrNotFound
+	}
+
+	buf := b.Get([]byte(key))
+	if buf == nil {
+		return nil, store.ErrNotFound
+	}
+
+	return buf, nil
+}
+
+// put stores the encoded item and its expiration.
+func (s *Store) put(tx *boltdb.Tx, key string, value interface{}, expiration time.Duration) error {
+	b, err := tx.CreateBucketIfNotExists(bucket)
+
+	if err != nil {
+		return err
+	}
+
+	buf, err := store.MarshalWith(s.codec, value)
+
+	if err != nil {
+		return err
+	}
+
+	if err := b.Put([]byte(key), buf); err != nil {
+		return err
+	}
+
+	return setExpiry(tx, key, store.ExpiresAt(expiration))
+}
+
+// setIf stores the item if fn returns true for the current encoded
+// item, which is nil if the item don't exists or has expired. The
+// check and the write is done in the same transaction.
+func (s *Store) setIf(key string, value interface{}, expiration time.Duration, fn func([]byte) (bool, error)) (bool, error) {
+	var ok bool
+
+	err := s.update(func(tx *boltdb.Tx) error {
+		buf, err := get(tx, key)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+
+		if ok, err = fn(buf); err != nil || !ok {
+			return err
+		}
+
+		return s.put(tx, key, value, expiration)
+	})
+
+	return ok && err == nil, err
+}
+
+// Add will store a item in the cache only if the key don't exists.
+func (s *Store) Add(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return s.setIf(key, value, expiration, func(buf []byte) (bool, error) {
+		return buf == nil, nil
+	})
 }
 
 // Close store.
 func (s *Store) Close() error {
-	return s.db.Close()
+	s.once.Do(func() {
+		close(s.stop)
+	})
+
+	// Pending writes are written before the database is closed.
+	err := s.Sync()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cerr := s.db.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
 }
 
-// Flush remove all items from the cache.
+// CompareAndSwap will store the new value in the cache only if the
+// current value is equal to the encoded form of the old value.
+func (s *Store) CompareAndSwap(key string, old, new interface{}, expiration time.Duration) (bool, error) {
+	o, err := store.MarshalWith(s.codec, old)
+	if err != nil {
+		return false, err
+	}
+
+	return s.setIf(key, new, expiration, func(buf []byte) (bool, error) {
+		return buf != nil && bytes.Equal(buf, o), nil
+	})
+}
+
+// Decrement will decrement a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Decrement(key string, n ...int64) (int64, error) {
+	return s.increment(key, -store.Delta(n...))
+}
+
+// Flush remove all items from the cache by deleting and creating
+// the buckets again in one transaction.
 func (s *Store) Flush() error {
-	return s.db.Update(func(tx *boltdb.Tx) error {
-		if err := tx.DeleteBucket(bucketTTL); err == nil {
-			_, err := tx.CreateBucket(bucketTTL)
-			return err
+	return s.update(func(tx *boltdb.Tx) error {
+		for _, name := range [][]byte{bucket, bucketTTL, bucketExpiry} {
+			err := tx.DeleteBucket(name)
+			if err != nil && err != boltdb.ErrBucketNotFound {
+				return err
+			}
 		}
 
-		if err := tx.DeleteBucket(bucket); err != nil {
-			return err
+		return createBuckets(tx)
+	})
+}
+
+// FlushPrefix will remove all items with keys that starts with the
+// prefix in one transaction. Keys are sorted in bolt so the cursor
+// only has to visit the keys with the prefix.
+func (s *Store) FlushPrefix(prefix string) error {
+	p := []byte(prefix)
+
+	return s.update(func(tx *boltdb.Tx) error {
+		for _, name := range [][]byte{bucket, bucketTTL} {
+			b := tx.Bucket(name)
+			if b == nil {
+				continue
+			}
+
+			var keys [][]byte
+
+			c := b.Cursor()
+			for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+				keys = append(keys, k)
+			}
+
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
 		}
 
-		_, err := tx.CreateBucket(bucket)
-		return err
+		return nil
 	})
 }
 
 // Get will retrieve a item from the cache.
 func (s *Store) Get(key string) (interface{}, error) {
-	var i *store.Item
+	var v interface{}
 
-	if err := s.Result(key, &i); err != nil {
+	if err := s.Result(key, &v); err != nil {
 		return nil, err
 	}
 
-	if i.Object != nil {
-		return i.Object, nil
-	}
+	return v, nil
+}
 
-	var i2 interface{}
+// GetMulti will retrieve multiple items from the cache in one
+// transaction, items that don't exists are left out of the result.
+func (s *Store) GetMulti(keys []string) (map[string]interface{}, error) {
+	items := make(map[string]interface{}, len(keys))
 
-	if err := s.Result(key, &i2); err != nil {
+	if err := s.Sync(); err != nil {
 		return nil, err
 	}
 
-	return i2, nil
+	err := s.view(func(tx *boltdb.Tx) error {
+		for _, key := range keys {
+			buf, err := get(tx, key)
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			var v interface{}
+			if err := store.Unmarshal(buf, &v); err != nil {
+				return err
+			}
+
+			items[key] = v
+		}
+
+		return nil
+	})
+
+	return items, err
 }
 
-// Remove will remove a item from the cache.
+// Increment will increment a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Increment(key string, n ...int64) (int64, error) {
+	return s.increment(key, store.Delta(n...))
+}
+
+func (s *Store) increment(key string, n int64) (int64, error) {
+	var v int64
+
+	err := s.update(func(tx *boltdb.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+
+		bt, err := tx.CreateBucketIfNotExists(bucketTTL)
+		if err != nil {
+			return err
+		}
+
+		ok, err := expired(bt, key)
+		if err != nil {
+			return err
+		}
+
+		if buf := b.Get([]byte(key)); len(buf) > 0 && !ok {
+			if err := store.Unmarshal(buf, &v); err != nil {
+				return err
+			}
+		} else if err := setExpiry(tx, key, 0); err != nil {
+			return err
+		}
+
+		v += n
+
+		buf, err := store.MarshalWith(s.codec, v)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), buf)
+	})
+
+	return v, err
+}
+
+// Len will retrieve the number of items in the cache that has not expired.
+func (s *Store) Len() (int, error) {
+	n := 0
+
+	err := s.Scan("", func(string) bool {
+		n++
+		return true
+	})
+
+	return n, err
+}
+
+// Number will retrieve a number from the cache.
+func (s *Store) Number(key string) (int64, error) {
+	var v int64
+
+	if err := s.Result(key, &v); err != nil {
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// Persist will remove the expiration of a item in the cache.
+func (s *Store) Persist(key string) error {
+	return s.Touch(key, 0)
+}
+
+// Remove will remove a item from the cache, store.ErrNotFound
+// is returned if the item don't exists or has expired.
 func (s *Store) Remove(key string) error {
-	return s.db.Update(func(tx *boltdb.Tx) error {
-		b := tx.Bucket(bucketTTL)
-		b.Delete(bucketTTL)
-		b = tx.Bucket(bucket)
-		return b.Delete([]byte(key))
+	if s.window > 0 {
+		if err := s.exists(key); err != nil {
+			return err
+		}
+
+		return s.postpone(key, nil, 0)
+	}
+
+	// A missing item is not returned from fn, since an error would
+	// make db.Batch roll back and retry the other writes in the batch.
+	var missing error
+
+	err := s.write(func(tx *boltdb.Tx) error {
+		missing = nil
+
+		if _, err := get(tx, key); err != nil {
+			if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrExpired) {
+				missing = err
+				return nil
+			}
+
+			return err
+		}
+
+		return remove(tx, key)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return missing
+}
+
+// remove removes the item and its expiration.
+func remove(tx *boltdb.Tx, key string) error {
+	if err := tx.Bucket(bucket).Delete([]byte(key)); err != nil {
+		return err
+	}
+
+	return deleteExpiry(tx, key)
+}
+
+// exists returns a error if the item don't exists
+// as a pending write or in the database.
+func (s *Store) exists(key string) error {
+	if _, ok, err := s.postponed(key); ok {
+		return err
+	}
+
+	return s.view(func(tx *boltdb.Tx) error {
+		_, err := get(tx, key)
+		return err
+	})
+}
+
+// RemoveMulti will remove multiple items from the cache in one
+// transaction, keys that don't exists are ignored.
+func (s *Store) RemoveMulti(keys []string) error {
+	return s.write(func(tx *boltdb.Tx) error {
+		for _, key := range keys {
+			if err := remove(tx, key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Replace will store a item in the cache only if the key exists.
+func (s *Store) Replace(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return s.setIf(key, value, expiration, func(buf []byte) (bool, error) {
+		return buf != nil, nil
 	})
 }
 
 // Result will retrieve a item from the cache and stores the
 // result in the value pointed to by value.
 func (s *Store) Result(key string, value interface{}) error {
-	return s.db.View(func(tx *boltdb.Tx) error {
-		b := tx.Bucket(bucketTTL)
+	if buf, ok, err := s.postponed(key); ok {
+		if err != nil {
+			return err
+		}
 
-		exp := b.Get([]byte(key))
-		if len(exp) > 0 {
-			i, err := strconv.ParseInt(string(exp), 10, 64)
-			if err != nil {
-				return err
-			}
+		return store.Unmarshal(buf, value)
+	}
 
-			if time.Now().UnixNano() > i && i != 0 {
-				return fmt.Errorf("Item %s not found", key)
-			}
+	return s.view(func(tx *boltdb.Tx) error {
+		buf, err := get(tx, key)
+		if err != nil {
+			return err
 		}
 
-		b = tx.Bucket(bucket)
-		buf := b.Get([]byte(key))
 		return store.Unmarshal(buf, value)
 	})
 }
 
-// Set will store a item in the cache.
-func (s *Store) Set(key string, value interface{}, expiration time.Duration) error {
-	return s.db.Update(func(tx *boltdb.Tx) error {
-		b, err := tx.CreateBucketIfNotExists(bucket)
+// Scan will call fn for each key in the cache that starts with the prefix.
+// The keys are read with a cursor in batches and fn is called outside of
+// the transaction so fn can modify the store.
+func (s *Store) Scan(prefix string, fn func(string) bool) error {
+	if err := s.Sync(); err != nil {
+		return err
+	}
+
+	p := []byte(prefix)
+	seek := p
+	last := []byte(nil)
+
+	for {
+		var keys []string
+
+		err := s.view(func(tx *boltdb.Tx) error {
+			b := tx.Bucket(bucket)
+			if b == nil {
+				return nil
+			}
+
+			bt := tx.Bucket(bucketTTL)
+			c := b.Cursor()
+
+			// Skip the last key of the previous batch.
+			k, _ := c.Seek(seek)
+			if last != nil && bytes.Equal(k, last) {
+				k, _ = c.Next()
+			}
+
+			for ; k != nil && bytes.HasPrefix(k, p) && len(keys) < scanBatch; k, _ = c.Next() {
+				if bt != nil {
+					ok, err := expired(bt, string(k))
+					if err != nil {
+						return err
+					}
+
+					if ok {
+						continue
+					}
+				}
+
+				keys = append(keys, string(k))
+			}
+
+			return nil
+		})
 
 		if err != nil {
 			return err
 		}
 
-		buf, err := store.Marshal(value)
+		for _, k := range keys {
+			if !fn(k) {
+				return nil
+			}
+		}
+
+		if len(keys) < scanBatch {
+			return nil
+		}
 
+		last = []byte(keys[len(keys)-1])
+		seek = last
+	}
+}
+
+// Set will store a item in the cache.
+func (s *Store) Set(key string, value interface{}, expiration time.Duration) error {
+	if s.window > 0 {
+		buf, err := store.MarshalWith(s.codec, value)
 		if err != nil {
 			return err
 		}
 
-		if err := b.Put([]byte(key), buf); err != nil {
+		return s.postpone(key, buf, store.ExpiresAt(expiration))
+	}
+
+	return s.write(func(tx *boltdb.Tx) error {
+		return s.put(tx, key, value, expiration)
+	})
+}
+
+// SetMulti will store multiple items in the cache in one transaction.
+func (s *Store) SetMulti(items map[string]interface{}, expiration time.Duration) error {
+	return s.write(func(tx *boltdb.Tx) error {
+		for key, value := range items {
+			if err := s.put(tx, key, value, expiration); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Touch will set a new expiration on a item in the cache
+// by only updating the ttl bucket.
+func (s *Store) Touch(key string, ttl time.Duration) error {
+	return s.update(func(tx *boltdb.Tx) error {
+		if _, err := get(tx, key); err != nil {
 			return err
 		}
 
-		b, err = tx.CreateBucketIfNotExists(bucketTTL)
+		return setExpiry(tx, key, store.ExpiresAt(ttl))
+	})
+}
+
+// TTL will retrieve the remaining time to live of a item in the cache.
+func (s *Store) TTL(key string) (time.Duration, error) {
+	var ttl time.Duration
+
+	if err := s.Sync(); err != nil {
+		return 0, err
+	}
+
+	err := s.view(func(tx *boltdb.Tx) error {
+		if _, err := get(tx, key); err != nil {
+			return err
+		}
 
+		b := tx.Bucket(bucketTTL)
+		if b == nil {
+			return nil
+		}
+
+		exp := b.Get([]byte(key))
+		if len(exp) == 0 {
+			return nil
+		}
+
+		i, err := strconv.ParseInt(string(exp), 10, 64)
 		if err != nil {
 			return err
 		}
 
-		return b.Put([]byte(key), []byte(fmt.Sprintf("%d", expiration)))
+		ttl = store.TTL(i)
+
+		return nil
 	})
+
+	return ttl, err
 }
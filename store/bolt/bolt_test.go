@@ -1,9 +1,18 @@
 package bolt
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	boltdb "github.com/boltdb/bolt"
+	"github.com/frozzare/go-cache/store"
 )
 
 func TestStore(t *testing.T) {
@@ -67,12 +76,18 @@ func TestStoreExpired(t *testing.T) {
 
 	defer c.Close()
 
-	if err := c.Set("test", "test", 1); err != nil {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	if err := c.Set("test", "test", time.Second); err != nil {
 		t.Fatal(err)
 	}
 
-	if _, err := c.Get("test"); err == nil {
-		t.Error("Expected error, got nil")
+	now = now.Add(time.Second)
+
+	if _, err := c.Get("test"); !errors.Is(err, store.ErrExpired) {
+		t.Errorf("Expected store.ErrExpired, got %v", err)
 	}
 }
 
@@ -103,3 +118,1048 @@ func TestStoreStruct(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestStoreIncrement(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	c.Remove("number")
+
+	if v, err := c.Increment("number"); err != nil || v != 1 {
+		t.Fatalf("Expected 1, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Increment("number", 5); err != nil || v != 6 {
+		t.Fatalf("Expected 6, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number", 2); err != nil || v != 4 {
+		t.Fatalf("Expected 4, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Number("number"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+
+	if err := c.Remove("number"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreIncrementConcurrent(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	c.Remove("number")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := c.Increment("number"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if v, err := c.Number("number"); err != nil || v != 50 {
+		t.Fatalf("Expected 50, got %d (%v)", v, err)
+	}
+
+	if err := c.Remove("number"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreNotFound(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if _, err := c.Get("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	var v string
+	if err := c.Result("missing", &v); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if _, err := c.Number("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	tests := []time.Duration{
+		time.Millisecond,
+		100 * time.Millisecond,
+		time.Second,
+		2 * time.Hour,
+		48 * time.Hour,
+	}
+
+	for _, ttl := range tests {
+		now = time.Now()
+
+		if err := c.Set("ttl", "go", ttl); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(ttl - time.Nanosecond)
+		if v, err := c.Get("ttl"); err != nil || v != "go" {
+			t.Fatalf("Expected item with ttl %s to exist, got %v (%v)", ttl, v, err)
+		}
+
+		now = now.Add(time.Nanosecond)
+		if _, err := c.Get("ttl"); !errors.Is(err, store.ErrExpired) {
+			t.Fatalf("Expected store.ErrExpired for ttl %s, got %v", ttl, err)
+		}
+	}
+}
+
+func TestStoreIncrementTTL(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if err := c.Set("number", 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Increment("number"); err != nil || v != 2 {
+		t.Fatalf("Expected 2, got %d (%v)", v, err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := c.Number("number"); !errors.Is(err, store.ErrExpired) {
+		t.Fatalf("Expected store.ErrExpired, got %v", err)
+	}
+}
+
+func TestStoreCodec(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil, WithCodec(store.JSONCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if err := c.Set("codec", []int{1, 2, 3}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var v []int
+	if err := c.Result("codec", &v); err != nil || !reflect.DeepEqual(v, []int{1, 2, 3}) {
+		t.Fatalf("%v does not match the expected value: [1 2 3] (%v)", v, err)
+	}
+
+	c.Remove("number")
+
+	if _, err := c.Increment("number", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := c.Number("number"); err != nil || n != 2 {
+		t.Fatalf("Expected 2, got %d (%v)", n, err)
+	}
+
+	c.Remove("number")
+	c.Remove("codec")
+}
+
+func TestStoreMulti(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	s := c.(store.BatchStore)
+
+	items := map[string]interface{}{
+		"a": "go",
+		"b": 1,
+		"c": []string{"abc"},
+	}
+
+	if err := s.SetMulti(items, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.GetMulti([]string{"a", "b", "c", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(r, items) {
+		t.Fatalf("%v does not match the expected value: %v", r, items)
+	}
+
+	if err := s.RemoveMulti([]string{"a", "b", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = s.GetMulti([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 || r["c"] == nil {
+		t.Fatalf("Expected only c, got %v", r)
+	}
+
+	c.Remove("c")
+}
+
+func TestStoreFlushPrefix(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	for _, k := range []string{"a:1", "a:2", "ab", "b:1"} {
+		if err := c.Set(k, k, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.(store.PrefixStore).FlushPrefix("a:"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a:1", "a:2"} {
+		if _, err := c.Get(k); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected store.ErrNotFound for %s, got %v", k, err)
+		}
+	}
+
+	for _, k := range []string{"ab", "b:1"} {
+		if v, err := c.Get(k); err != nil || v != k {
+			t.Fatalf("Expected %s, got %v (%v)", k, v, err)
+		}
+
+		c.Remove(k)
+	}
+}
+
+func TestStoreConditional(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	s := c.(store.ConditionalStore)
+
+	c.Remove("cond")
+
+	if ok, err := s.Replace("cond", "a", 0); err != nil || ok {
+		t.Fatalf("Expected replace of missing key to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Add("cond", "a", 0); err != nil || !ok {
+		t.Fatalf("Expected add to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Add("cond", "b", 0); err != nil || ok {
+		t.Fatalf("Expected add of existing key to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Replace("cond", "b", 0); err != nil || !ok {
+		t.Fatalf("Expected replace to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.CompareAndSwap("cond", "a", "c", 0); err != nil || ok {
+		t.Fatalf("Expected compare and swap with wrong value to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.CompareAndSwap("cond", "b", "c", time.Hour); err != nil || !ok {
+		t.Fatalf("Expected compare and swap to succeed, got %v (%v)", ok, err)
+	}
+
+	if v, err := c.Get("cond"); err != nil || v != "c" {
+		t.Fatalf("Expected c, got %v (%v)", v, err)
+	}
+
+	if err := c.Remove("cond"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreTouch(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	s := c.(store.TTLStore)
+
+	if _, err := s.TTL("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := s.Touch("missing", time.Hour); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := s.Persist("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Set("touch", "go", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("Expected ttl of at most a minute, got %s (%v)", ttl, err)
+	}
+
+	if err := s.Touch("touch", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl <= time.Minute || ttl > time.Hour {
+		t.Fatalf("Expected ttl of at most a hour, got %s (%v)", ttl, err)
+	}
+
+	if err := s.Persist("touch"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl != 0 {
+		t.Fatalf("Expected no ttl, got %s (%v)", ttl, err)
+	}
+
+	if v, err := c.Get("touch"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	if err := c.Remove("touch"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreScan(t *testing.T) {
+	c, err := NewStore("store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2500; i++ {
+		if err := c.Set(fmt.Sprintf("scan:%04d", i), i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Set("other", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	s := c.(store.ScanStore)
+
+	keys := make(map[string]bool)
+	err = s.Scan("scan:", func(key string) bool {
+		keys[key] = true
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2500 || keys["other"] {
+		t.Fatalf("Expected 2500 keys, got %d", len(keys))
+	}
+
+	n := 0
+	err = s.Scan("", func(key string) bool {
+		n++
+		return n < 10
+	})
+
+	if err != nil || n != 10 {
+		t.Fatalf("Expected scan to stop after 10 keys, got %d (%v)", n, err)
+	}
+
+	if n, err := s.Len(); err != nil || n != 2501 {
+		t.Fatalf("Expected 2501, got %d (%v)", n, err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreDeleteExpired(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c, err := NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil, WithCleanupBatch(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	for i := 0; i < 25; i++ {
+		if err := c.Set(fmt.Sprintf("expired:%d", i), i, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Set("forever", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Set("later", "go", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new expiration replaces the old expiry entry.
+	if err := c.Set("expired:0", 0, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+
+	if err := c.(*Store).DeleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.(*Store).view(func(tx *boltdb.Tx) error {
+		if n := tx.Bucket(bucket).Stats().KeyN; n != 3 {
+			t.Fatalf("Expected 3 items, got %d", n)
+		}
+
+		if n := tx.Bucket(bucketExpiry).Stats().KeyN; n != 2 {
+			t.Fatalf("Expected 2 expiry entries, got %d", n)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"expired:0", "forever", "later"} {
+		if _, err := c.Get(k); err != nil {
+			t.Fatalf("Expected %s to exist, got %v", k, err)
+		}
+	}
+}
+
+func TestStoreCleanupInterval(t *testing.T) {
+	c, err := NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil, WithCleanupInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if err := c.Set("expired", "go", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	err = c.(*Store).view(func(tx *boltdb.Tx) error {
+		if v := tx.Bucket(bucket).Get([]byte("expired")); v != nil {
+			t.Fatal("Expected expired item to be removed")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreCompact(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "store.db")
+
+	c, err := NewStore(name, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	items := make(map[string]interface{})
+	for i := 0; i < 5000; i++ {
+		items[fmt.Sprintf("compact:%d", i)] = strings.Repeat("go", 100)
+	}
+
+	if err := c.(store.BatchStore).SetMulti(items, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.(store.PrefixStore).FlushPrefix("compact:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Set("ttl", "go", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.(*Store).Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after.Size() >= before.Size() {
+		t.Fatalf("Expected file to shrink, got %d bytes before and %d after", before.Size(), after.Size())
+	}
+
+	if v, err := c.Get("compact:2"); err != nil || v != strings.Repeat("go", 100) {
+		t.Fatalf("Expected item to exist after compact, got %v", err)
+	}
+
+	if _, err := c.Get("compact:1"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if ttl, err := c.(store.TTLStore).TTL("ttl"); err != nil || ttl <= 0 {
+		t.Fatalf("Expected ttl to be kept, got %s (%v)", ttl, err)
+	}
+
+	if n, err := c.(store.ScanStore).Len(); err != nil || n != 5000-1111+1 {
+		t.Fatalf("Expected %d items, got %d (%v)", 5000-1111+1, n, err)
+	}
+}
+
+func TestStoreCompactStale(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "store.db")
+
+	// The stale file is left as if a earlier compaction crashed.
+	stale, err := NewStore(name+".compact", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stale.Set("ghost", "boo", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stale.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewStore(name, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if err := c.Set("name", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.(*Store).Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("ghost"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if v, err := c.Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+}
+
+func TestStoreBatch(t *testing.T) {
+	c, err := NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil, WithBatch())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if err := c.Set(fmt.Sprintf("batch:%d", i), i, 0); err != nil {
+				t.Error(err)
+			}
+
+			if err := c.Remove(fmt.Sprintf("missing:%d", i)); !errors.Is(err, store.ErrNotFound) {
+				t.Errorf("Expected store.ErrNotFound, got %v", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n, err := c.(store.ScanStore).Len(); err != nil || n != 50 {
+		t.Fatalf("Expected 50 items, got %d (%v)", n, err)
+	}
+
+	if v, err := c.Number("batch:7"); err != nil || v != 7 {
+		t.Fatalf("Expected 7, got %d (%v)", v, err)
+	}
+}
+
+func TestStoreWriteBehind(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "store.db")
+
+	c, err := NewStore(name, 0600, nil, WithWriteBehind(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := c.(*Store)
+
+	if err := c.Set("name", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	s.view(func(tx *boltdb.Tx) error {
+		if b := tx.Bucket(bucket); b != nil && b.Get([]byte("name")) != nil {
+			t.Fatal("Expected write to be pending")
+		}
+
+		return nil
+	})
+
+	if err := c.Remove("name"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("name"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Set("name", "bolt", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other operations writes the pending writes first.
+	if ok, err := c.(store.ConditionalStore).Add("name", "go", 0); err != nil || ok {
+		t.Fatalf("Expected add to fail, got %v (%v)", ok, err)
+	}
+
+	if err := c.Set("lang", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = NewStore(name, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	for k, e := range map[string]string{"name": "bolt", "lang": "go"} {
+		if v, err := c.Get(k); err != nil || v != e {
+			t.Fatalf("Expected %s, got %v (%v)", e, v, err)
+		}
+	}
+}
+
+func TestStoreWriteBehindWindow(t *testing.T) {
+	c, err := NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil, WithWriteBehind(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if err := c.Set("name", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	s := c.(*Store)
+	s.wmu.Lock()
+	n := len(s.pending)
+	s.wmu.Unlock()
+
+	if n != 0 {
+		t.Fatalf("Expected no pending writes, got %d", n)
+	}
+}
+
+func TestStoreWriteBehindDuringSync(t *testing.T) {
+	c, err := NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil, WithWriteBehind(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	s := c.(*Store)
+
+	// The lock makes the background sync wait
+	// until the second write has been postponed.
+	s.mu.Lock()
+
+	if err := c.Set("a", "1", 0); err != nil {
+		s.mu.Unlock()
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.wmu.Lock()
+		started := s.timer == nil
+		s.wmu.Unlock()
+
+		if started {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			s.mu.Unlock()
+			t.Fatal("Expected the background sync to start")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := c.Set("b", "2", 0); err != nil {
+		s.mu.Unlock()
+		t.Fatal(err)
+	}
+
+	s.wmu.Lock()
+	scheduled := s.timer != nil
+	s.wmu.Unlock()
+
+	s.mu.Unlock()
+
+	if !scheduled {
+		t.Fatal("Expected a write during a sync to schedule a new sync")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	s.wmu.Lock()
+	n := len(s.pending)
+	s.wmu.Unlock()
+
+	if n != 0 {
+		t.Fatalf("Expected no pending writes, got %d", n)
+	}
+
+	for k, e := range map[string]string{"a": "1", "b": "2"} {
+		if v, err := c.Get(k); err != nil || v != e {
+			t.Fatalf("Expected %s, got %v (%v)", e, v, err)
+		}
+	}
+}
+
+func BenchmarkStoreSet(b *testing.B) {
+	modes := []struct {
+		name    string
+		options []Option
+	}{
+		{"Update", nil},
+		{"Batch", []Option{WithBatch()}},
+		{"WriteBehind", []Option{WithWriteBehind(10 * time.Millisecond)}},
+	}
+
+	// Remove sets a third of the keys it is given and removes the rest,
+	// so both removes of stored and missing keys are measured.
+	ops := []struct {
+		name string
+		fn   func(c store.Store, n int64, key string) error
+	}{
+		{"Set", func(c store.Store, n int64, key string) error {
+			return c.Set(key, "go", 0)
+		}},
+		{"Remove", func(c store.Store, n int64, key string) error {
+			if n%3 == 0 {
+				return c.Set(key, "go", 0)
+			}
+
+			if err := c.Remove(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+
+			return nil
+		}},
+	}
+
+	for _, m := range modes {
+		for _, op := range ops {
+			b.Run(m.name+"/"+op.name, func(b *testing.B) {
+				c, err := NewStore(filepath.Join(b.TempDir(), "store.db"), 0600, nil, m.options...)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				defer c.Close()
+
+				var n int64
+				var mu sync.Mutex
+
+				b.SetParallelism(16)
+				b.ResetTimer()
+
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						mu.Lock()
+						n++
+						i := n
+						key := fmt.Sprintf("bench:%d", n%1000)
+						mu.Unlock()
+
+						if err := op.fn(c, i, key); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+
+				if err := c.(*Store).Sync(); err != nil {
+					b.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+func TestStoreRemove(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	tests := []struct {
+		name    string
+		options []Option
+		setup   func(store.Store)
+		err     error
+	}{
+		{
+			name: "missing before first set",
+			err:  store.ErrNotFound,
+		},
+		{
+			name:  "existing",
+			setup: func(c store.Store) { c.Set("key", "go", 0) },
+		},
+		{
+			name:  "existing with ttl",
+			setup: func(c store.Store) { c.Set("key", "go", time.Hour) },
+		},
+		{
+			name: "expired",
+			setup: func(c store.Store) {
+				c.Set("key", "go", time.Second)
+				now = now.Add(time.Minute)
+			},
+			err: store.ErrExpired,
+		},
+		{
+			name: "removed",
+			setup: func(c store.Store) {
+				c.Set("key", "go", 0)
+				c.Remove("key")
+			},
+			err: store.ErrNotFound,
+		},
+		{
+			name:    "missing with write-behind",
+			options: []Option{WithWriteBehind(time.Hour)},
+			err:     store.ErrNotFound,
+		},
+		{
+			name:    "pending with write-behind",
+			options: []Option{WithWriteBehind(time.Hour)},
+			setup:   func(c store.Store) { c.Set("key", "go", 0) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil, tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer c.Close()
+
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			if err := c.Remove("key"); !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("Expected %v, got %v", tt.err, err)
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			if _, err := c.Get("key"); !errors.Is(err, store.ErrNotFound) {
+				t.Fatalf("Expected store.ErrNotFound, got %v", err)
+			}
+
+			if _, err := c.(store.TTLStore).TTL("key"); !errors.Is(err, store.ErrNotFound) {
+				t.Fatalf("Expected store.ErrNotFound for ttl, got %v", err)
+			}
+
+			c.(*Store).view(func(tx *boltdb.Tx) error {
+				for _, name := range [][]byte{bucket, bucketTTL, bucketExpiry} {
+					if n := tx.Bucket(name).Stats().KeyN; n != 0 {
+						t.Fatalf("Expected bucket %s to be empty, got %d keys", name, n)
+					}
+				}
+
+				return nil
+			})
+		})
+	}
+}
+
+func TestStoreFlush(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(store.Store)
+	}{
+		{
+			name: "before first set",
+		},
+		{
+			name: "items without ttl",
+			setup: func(c store.Store) {
+				c.Set("a", "go", 0)
+				c.Set("b", "go", 0)
+			},
+		},
+		{
+			name: "items with ttl",
+			setup: func(c store.Store) {
+				c.Set("a", "go", time.Hour)
+				c.Set("b", "go", 0)
+				c.Increment("c")
+			},
+		},
+		{
+			name: "flushed twice",
+			setup: func(c store.Store) {
+				c.Set("a", "go", time.Hour)
+				c.Flush()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewStore(filepath.Join(t.TempDir(), "store.db"), 0600, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer c.Close()
+
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			if err := c.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			c.(*Store).view(func(tx *boltdb.Tx) error {
+				for _, name := range [][]byte{bucket, bucketTTL, bucketExpiry} {
+					b := tx.Bucket(name)
+					if b == nil {
+						t.Fatalf("Expected bucket %s to exist", name)
+					}
+
+					if n := b.Stats().KeyN; n != 0 {
+						t.Fatalf("Expected bucket %s to be empty, got %d keys", name, n)
+					}
+				}
+
+				return nil
+			})
+
+			if err := c.Set("a", "go", 0); err != nil {
+				t.Fatal(err)
+			}
+
+			if v, err := c.Get("a"); err != nil || v != "go" {
+				t.Fatalf("Expected go, got %v (%v)", v, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	boltdb "github.com/boltdb/bolt"
+	"github.com/frozzare/go-cache/store"
+)
+
+// compactBatch is the number of items that are copied
+// in one transaction by Compact.
+const compactBatch = 10000
+
+// indexKey returns the key in the expiry bucket for a item, the
+// big endian expiration first so the keys are sorted by expiration.
+func indexKey(exp int64, key string) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(exp))
+	copy(k[8:], key)
+	return k
+}
+
+// setExpiry stores the expiration of a item in the ttl bucket and
+// replaces the items entry in the expiry bucket. Items that never
+// expires has no entry in the expiry bucket.
+func setExpiry(tx *boltdb.Tx, key string, exp int64) error {
+	bt, err := tx.CreateBucketIfNotExists(bucketTTL)
+	if err != nil {
+		return err
+	}
+
+	bi, err := tx.CreateBucketIfNotExists(bucketExpiry)
+	if err != nil {
+		return err
+	}
+
+	if old := bt.Get([]byte(key)); len(old) > 0 {
+		i, err := strconv.ParseInt(string(old), 10, 64)
+		if err == nil && i > 0 {
+			if err := bi.Delete(indexKey(i, key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if exp > 0 {
+		if err := bi.Put(indexKey(exp, key), nil); err != nil {
+			return err
+		}
+	}
+
+	return bt.Put([]byte(key), []byte(fmt.Sprintf("%d", exp)))
+}
+
+// deleteExpiry removes the expiration of a item from the ttl
+// bucket and the items entry in the expiry bucket.
+func deleteExpiry(tx *boltdb.Tx, key string) error {
+	if err := setExpiry(tx, key, 0); err != nil {
+		return err
+	}
+
+	return tx.Bucket(bucketTTL).Delete([]byte(key))
+}
+
+// reindex creates the expiry bucket from the ttl bucket for
+// databases that was created before the expiry bucket existed.
+func reindex(tx *boltdb.Tx) error {
+	if tx.Bucket(bucketExpiry) != nil {
+		return nil
+	}
+
+	bi, err := tx.CreateBucket(bucketExpiry)
+	if err != nil {
+		return err
+	}
+
+	bt := tx.Bucket(bucketTTL)
+	if bt == nil {
+		return nil
+	}
+
+	return bt.ForEach(func(k, v []byte) error {
+		i, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil || i <= 0 {
+			return nil
+		}
+
+		return bi.Put(indexKey(i, string(k)), nil)
+	})
+}
+
+// janitor removes expired items at the configured interval
+// until the store is closed.
+func (s *Store) janitor() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.DeleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// DeleteExpired will remove all expired items from the store. The
+// items are removed in batches with one transaction for each batch.
+func (s *Store) DeleteExpired() error {
+	for {
+		n, err := s.sweep(s.batch)
+		if err != nil || n < s.batch {
+			return err
+		}
+	}
+}
+
+// sweep removes at most n expired items in one transaction and returns
+// the number of expiry entries that was removed. The expiry bucket is
+// sorted by expiration so only the expired entries are visited. Entries
+// that don't match the ttl bucket are left from removed items and are
+// removed without touching the item.
+func (s *Store) sweep(n int) (int, error) {
+	var count int
+
+	err := s.update(func(tx *boltdb.Tx) error {
+		bi := tx.Bucket(bucketExpiry)
+		if bi == nil {
+			return nil
+		}
+
+		var keys [][]byte
+
+		c := bi.Cursor()
+		for k, _ := c.First(); k != nil && len(keys) < n; k, _ = c.Next() {
+			if len(k) >= 8 && !store.Expired(int64(binary.BigEndian.Uint64(k))) {
+				break
+			}
+
+			keys = append(keys, k)
+		}
+
+		b := tx.Bucket(bucket)
+		bt := tx.Bucket(bucketTTL)
+
+		for _, k := range keys {
+			if err := bi.Delete(k); err != nil {
+				return err
+			}
+
+			if len(k) < 8 || b == nil || bt == nil {
+				continue
+			}
+
+			key := k[8:]
+			exp := strconv.FormatInt(int64(binary.BigEndian.Uint64(k)), 10)
+
+			if !bytes.Equal(bt.Get(key), []byte(exp)) {
+				continue
+			}
+
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+
+			if err := bt.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		count = len(keys)
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Compact will remove all expired items and rewrite the database to
+// a new file that replaces the current file. Bolt never shrinks the
+// database file by itself, so this is the only way to get the disk
+// space back after many items has been removed. The store can't be
+// used while it's compacted.
+func (s *Store) Compact() error {
+	if err := s.DeleteExpired(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.db.Path()
+	tmp := path + ".compact"
+
+	// A file left by a earlier compaction that crashed
+	// would bring back items that has been removed.
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	dst, err := boltdb.Open(tmp, s.perm, s.opts)
+	if err != nil {
+		return err
+	}
+
+	if err := compact(dst, s.db); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := s.db.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// The current file is opened again if the rename fails.
+	rerr := os.Rename(tmp, path)
+	if rerr != nil {
+		os.Remove(tmp)
+	}
+
+	db, err := boltdb.Open(path, s.perm, s.opts)
+	if err != nil {
+		return err
+	}
+
+	s.db = db
+
+	return rerr
+}
+
+// compact copies all buckets in src to dst, with one transaction
+// for each batch of items so large databases can be copied.
+func compact(dst, src *boltdb.DB) error {
+	return src.View(func(tx *boltdb.Tx) error {
+		return tx.ForEach(func(name []byte, b *boltdb.Bucket) error {
+			c := b.Cursor()
+			k, v := c.First()
+
+			for {
+				err := dst.Update(func(tx *boltdb.Tx) error {
+					db, err := tx.CreateBucketIfNotExists(name)
+					if err != nil {
+						return err
+					}
+
+					// The keys are added in order so the pages can be filled.
+					db.FillPercent = 1
+
+					for i := 0; k != nil && i < compactBatch; k, v = c.Next() {
+						if err := db.Put(k, v); err != nil {
+							return err
+						}
+
+						i++
+					}
+
+					return nil
+				})
+
+				if err != nil || k == nil {
+					return err
+				}
+			}
+		})
+	})
+}
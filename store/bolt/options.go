@@ -0,0 +1,57 @@
+package bolt
+
+import (
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// Option configures a bolt store.
+type Option func(*Store)
+
+// WithCodec sets the codec that is used to encode values.
+func WithCodec(c store.Codec) Option {
+	return func(s *Store) {
+		s.codec = c
+	}
+}
+
+// WithCleanupInterval will start a goroutine that removes expired
+// items from the store at the given interval. The goroutine is
+// stopped when the store is closed.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.interval = interval
+	}
+}
+
+// WithCleanupBatch sets the max number of expired items that are
+// removed in one transaction, so writes are not blocked for long
+// when many items expires at the same time.
+func WithCleanupBatch(n int) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.batch = n
+		}
+	}
+}
+
+// WithBatch makes Set, SetMulti, Remove and RemoveMulti use db.Batch,
+// so writes from concurrent goroutines are combined into one
+// transaction and share the cost of syncing the file.
+func WithBatch() Option {
+	return func(s *Store) {
+		s.useBatch = true
+	}
+}
+
+// WithWriteBehind makes Set and Remove return before the item is written
+// to the database. The writes are kept in memory and written together in
+// one transaction when the window has passed, or before any other
+// operation than Get and Result. Pending writes are lost if the process
+// exits before the store is closed or synced.
+func WithWriteBehind(window time.Duration) Option {
+	return func(s *Store) {
+		s.window = window
+	}
+}
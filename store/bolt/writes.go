@@ -0,0 +1,188 @@
+package bolt
+
+import (
+	"time"
+
+	boltdb "github.com/boltdb/bolt"
+	"github.com/frozzare/go-cache/store"
+)
+
+// maxPending is the number of pending writes in write-behind
+// mode that makes a write wait until the writes are written.
+const maxPending = 10000
+
+// write represents a pending write in write-behind mode,
+// a nil value means that the item is removed.
+type write struct {
+	value      []byte
+	expiration int64
+}
+
+// write runs fn in a read-write transaction, or with db.Batch so
+// concurrent writes share one transaction if WithBatch is used.
+// fn may be called more than once with db.Batch.
+func (s *Store) write(fn func(*boltdb.Tx) error) error {
+	if !s.useBatch {
+		return s.update(fn)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var writes map[string]*write
+
+	err := s.db.Batch(func(tx *boltdb.Tx) error {
+		var err error
+		if writes, err = s.apply(tx); err != nil {
+			return err
+		}
+
+		return fn(tx)
+	})
+
+	if err == nil {
+		s.applied(writes)
+	}
+
+	return err
+}
+
+// postpone adds a pending write that is written to the database when
+// the write-behind window has passed. A error from a earlier write
+// in the background is returned instead if there is one.
+func (s *Store) postpone(key string, value []byte, expiration int64) error {
+	s.wmu.Lock()
+
+	s.pending[key] = &write{value: value, expiration: expiration}
+	n := len(s.pending)
+
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.window, s.background)
+	}
+
+	err := s.werr
+	s.werr = nil
+
+	s.wmu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if n >= maxPending {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+// background writes the pending writes when the window has passed.
+// The timer is reset before the writes are written, so a write that
+// is postponed while they are written schedules a new timer.
+func (s *Store) background() {
+	s.wmu.Lock()
+	s.timer = nil
+	s.wmu.Unlock()
+
+	if err := s.Sync(); err != nil {
+		s.wmu.Lock()
+		s.werr = err
+		s.wmu.Unlock()
+	}
+}
+
+// postponed returns the pending write for the key, ok is false
+// if there is no pending write for the key.
+func (s *Store) postponed(key string) ([]byte, bool, error) {
+	s.wmu.Lock()
+	w, ok := s.pending[key]
+	s.wmu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, false, nil
+	case w.value == nil:
+		return nil, true, store.ErrNotFound
+	case store.Expired(w.expiration):
+		return nil, true, store.ErrExpired
+	}
+
+	return w.value, true, nil
+}
+
+// apply writes the pending writes in the transaction and returns
+// them so they can be removed when the transaction is committed.
+func (s *Store) apply(tx *boltdb.Tx) (map[string]*write, error) {
+	s.wmu.Lock()
+
+	if len(s.pending) == 0 {
+		s.wmu.Unlock()
+		return nil, nil
+	}
+
+	writes := make(map[string]*write, len(s.pending))
+	for k, w := range s.pending {
+		writes[k] = w
+	}
+
+	s.wmu.Unlock()
+
+	b, err := tx.CreateBucketIfNotExists(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, w := range writes {
+		if w.value == nil {
+			if err := remove(tx, k); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if err := b.Put([]byte(k), w.value); err != nil {
+			return nil, err
+		}
+
+		if err := setExpiry(tx, k, w.expiration); err != nil {
+			return nil, err
+		}
+	}
+
+	return writes, nil
+}
+
+// applied removes the written writes from the pending writes,
+// unless they have been replaced by a newer write.
+func (s *Store) applied(writes map[string]*write) {
+	if len(writes) == 0 {
+		return
+	}
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	for k, w := range writes {
+		if s.pending[k] == w {
+			delete(s.pending, k)
+		}
+	}
+}
+
+// Sync will write the pending writes to the database. Writes are
+// only pending when WithWriteBehind is used, all other operations
+// than Get, Result, Set and Remove writes them first.
+func (s *Store) Sync() error {
+	s.wmu.Lock()
+	n := len(s.pending)
+	s.wmu.Unlock()
+
+	if n == 0 {
+		return nil
+	}
+
+	return s.update(func(*boltdb.Tx) error {
+		return nil
+	})
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/pquerna/ffjson/ffjson"
+)
+
+// magic is the first byte of the header written before each payload.
+const magic = 0xca
+
+var (
+	// ErrInvalidPayload is returned when a payload don't start with
+	// a codec header or the header refers to a unknown codec.
+	ErrInvalidPayload = errors.New("invalid payload")
+
+	// GobCodec encodes values with gob, the value is wrapped in a Item
+	// so it can be decoded without knowing the type of the value.
+	GobCodec Codec = gobCodec{}
+
+	// JSONCodec encodes values with ffjson.
+	JSONCodec Codec = jsonCodec{}
+
+	// BytesCodec stores byte slices and strings as they are.
+	BytesCodec Codec = bytesCodec{}
+
+	codecs   = map[byte]Codec{}
+	codecsMu sync.RWMutex
+)
+
+func init() {
+	RegisterCodec(GobCodec)
+	RegisterCodec(JSONCodec)
+	RegisterCodec(BytesCodec)
+}
+
+// Codec provides a interface to implement value encodings.
+type Codec interface {
+	// ID returns the unique id that is written in the payload
+	// header to tell which codec that encoded the payload.
+	ID() byte
+	Marshal(interface{}) ([]byte, error)
+	Unmarshal([]byte, interface{}) error
+}
+
+// RegisterCodec will register a codec so payloads written
+// with it can be decoded by Unmarshal.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	codecs[c.ID()] = c
+	codecsMu.Unlock()
+}
+
+// DefaultCodec returns the codec that is used for the value when
+// no codec is configured. Byte slices are stored as they are, pointers,
+// structs and maps are encoded with JSON and everything else with gob.
+func DefaultCodec(value interface{}) Codec {
+	if _, ok := value.([]byte); ok {
+		return BytesCodec
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Ptr, reflect.Struct, reflect.Map:
+		return JSONCodec
+	default:
+		return GobCodec
+	}
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte {
+	return 1
+}
+
+func (gobCodec) Marshal(value interface{}) ([]byte, error) {
+	b := &bytes.Buffer{}
+
+	if err := gob.NewEncoder(b).Encode(&Item{Object: value}); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(buf []byte, value interface{}) error {
+	var i Item
+
+	if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&i); err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(value)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(i) {
+		return assign(value, i)
+	}
+
+	return assign(value, i.Object)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte {
+	return 2
+}
+
+func (jsonCodec) Marshal(value interface{}) ([]byte, error) {
+	return ffjson.Marshal(value)
+}
+
+func (jsonCodec) Unmarshal(buf []byte, value interface{}) error {
+	return ffjson.Unmarshal(buf, value)
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) ID() byte {
+	return 3
+}
+
+func (bytesCodec) Marshal(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("bytes codec can't encode %T", value)
+	}
+}
+
+func (bytesCodec) Unmarshal(buf []byte, value interface{}) error {
+	if s, ok := value.(*string); ok {
+		*s = string(buf)
+		return nil
+	}
+
+	return assign(value, append([]byte(nil), buf...))
+}
+
+// assign stores src in the value pointed to by dst, nil pointers
+// are allocated and numbers are converted to the type of dst.
+func assign(dst, src interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("can't decode into non-pointer %T", dst)
+	}
+
+	v := rv.Elem()
+	sv := reflect.ValueOf(src)
+
+	for {
+		if !sv.IsValid() {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		if sv.Type().AssignableTo(v.Type()) {
+			v.Set(sv)
+			return nil
+		}
+
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		v = v.Elem()
+	}
+
+	if isNumber(sv.Kind()) && isNumber(v.Kind()) {
+		v.Set(sv.Convert(v.Type()))
+		return nil
+	}
+
+	return fmt.Errorf("can't decode %T into %s", src, v.Type())
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		codec Codec
+		value interface{}
+	}{
+		{GobCodec, "go"},
+		{GobCodec, 1},
+		{GobCodec, []int{1, 2, 3}},
+		{JSONCodec, "go"},
+		{JSONCodec, []string{"abc"}},
+		{JSONCodec, map[string]string{"name": "go"}},
+		{BytesCodec, []byte("go")},
+	}
+
+	for _, test := range tests {
+		b, err := MarshalWith(test.codec, test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b[1] != test.codec.ID() {
+			t.Fatalf("Expected header for codec %d, got %d", test.codec.ID(), b[1])
+		}
+
+		v := reflect.New(reflect.TypeOf(test.value))
+		if err := Unmarshal(b, v.Interface()); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(v.Elem().Interface(), test.value) {
+			t.Fatalf("%v does not match the expected value: %v", v.Elem().Interface(), test.value)
+		}
+	}
+}
+
+func TestDefaultCodec(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		codec Codec
+	}{
+		{"go", GobCodec},
+		{1, GobCodec},
+		{[]byte("go"), BytesCodec},
+		{map[string]string{}, JSONCodec},
+		{&User{}, JSONCodec},
+		{User{}, JSONCodec},
+	}
+
+	for _, test := range tests {
+		if c := DefaultCodec(test.value); c != test.codec {
+			t.Errorf("Expected codec %d for %T, got %d", test.codec.ID(), test.value, c.ID())
+		}
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	tests := [][]byte{
+		nil,
+		{},
+		{magic},
+		{magic, 0},
+		[]byte(`{"name":`),
+		[]byte("go"),
+	}
+
+	for _, buf := range tests {
+		var v interface{}
+		if err := Unmarshal(buf, &v); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Expected ErrInvalidPayload for %v, got %v", buf, err)
+		}
+	}
+}
+
+func TestUnmarshalConvert(t *testing.T) {
+	b, err := MarshalWith(GobCodec, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var i int64
+	if err := Unmarshal(b, &i); err != nil || i != 42 {
+		t.Fatalf("Expected 42, got %d (%v)", i, err)
+	}
+
+	var f float64
+	if err := Unmarshal(b, &f); err != nil || f != 42 {
+		t.Fatalf("Expected 42, got %f (%v)", f, err)
+	}
+
+	var s string
+	if err := Unmarshal(b, &s); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	b, err = MarshalWith(BytesCodec, []byte("go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Unmarshal(b, &s); err != nil || s != "go" {
+		t.Fatalf("Expected go, got %s (%v)", s, err)
+	}
+}
+
+func TestUnmarshalLegacy(t *testing.T) {
+	// The payloads are written like Marshal did
+	// before payloads had a codec header.
+	item, err := GobCodec.Marshal("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		payload []byte
+		value   interface{}
+	}{
+		{item, "go"},
+		{[]byte(`{"name":"go"}`), map[string]interface{}{"name": "go"}},
+		{[]byte(`[1,2]`), []interface{}{1.0, 2.0}},
+	}
+
+	for _, test := range tests {
+		var v interface{}
+		if err := Unmarshal(test.payload, &v); err != nil || !reflect.DeepEqual(v, test.value) {
+			t.Errorf("Expected %v, got %v (%v)", test.value, v, err)
+		}
+	}
+}
@@ -0,0 +1,606 @@
+package file
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// version is the first byte of the file header.
+const version = 1
+
+// headerSize is the size of the version, the expiration
+// and the key length that starts every file.
+const headerSize = 1 + 8 + 4
+
+// tmpPrefix is the prefix of temporary files that are
+// renamed to the item files when they are written.
+const tmpPrefix = ".tmp-"
+
+// lockSuffix is the suffix of the lock files that are used
+// to increment and decrement numbers.
+const lockSuffix = ".lock"
+
+var (
+	// lockTimeout is how long Increment and Decrement waits for
+	// a lock held by another goroutine or process.
+	lockTimeout = 5 * time.Second
+
+	// staleAge is the age of lock and temporary files that are left
+	// by a process that has crashed, they are removed after it.
+	staleAge = time.Minute
+)
+
+var (
+	// errCorrupt is returned when a file can't be parsed.
+	errCorrupt = errors.New("file: corrupt cache file")
+
+	// errStop is used to stop a scan.
+	errStop = errors.New("stop scan")
+)
+
+// Store represents the file cache store. Each item is stored in its own
+// file in the directory, so the store can be shared by processes that
+// can't share a database lock.
+type Store struct {
+	dir      string
+	codec    store.Codec
+	interval time.Duration
+	maxBytes int64
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewStore will create a new file store in the given directory,
+// the directory is created if it don't exists.
+func NewStore(dir string, opts ...Option) (store.Store, error) {
+	s := &Store{
+		dir:  dir,
+		stop: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	if s.interval > 0 {
+		go s.janitor()
+	}
+
+	return s, nil
+}
+
+// path returns the path of the file for the key. The file name is
+// the sha1 hash of the key, stored in a sub directory named after
+// the first two characters of the hash so no directory gets too many
+// files.
+func (s *Store) path(key string) string {
+	h := sha1.Sum([]byte(key))
+	name := hex.EncodeToString(h[:])
+	return filepath.Join(s.dir, name[:2], name)
+}
+
+// item represents the content of a file.
+type item struct {
+	expiration int64
+	key        string
+	value      []byte
+}
+
+// encode returns the file content for the item.
+func (i *item) encode() []byte {
+	buf := make([]byte, headerSize+len(i.key)+len(i.value))
+	buf[0] = version
+	binary.BigEndian.PutUint64(buf[1:], uint64(i.expiration))
+	binary.BigEndian.PutUint32(buf[9:], uint32(len(i.key)))
+	copy(buf[headerSize:], i.key)
+	copy(buf[headerSize+len(i.key):], i.value)
+	return buf
+}
+
+// readHeader reads the header and the key from f. A key length that
+// is larger than the rest of the file is treated as a corrupt file.
+func readHeader(f *os.File) (*item, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var h [headerSize]byte
+	if _, err := io.ReadFull(f, h[:]); err != nil {
+		return nil, errCorrupt
+	}
+
+	if h[0] != version {
+		return nil, errCorrupt
+	}
+
+	n := int64(binary.BigEndian.Uint32(h[9:]))
+	if n > fi.Size()-headerSize {
+		return nil, errCorrupt
+	}
+
+	key := make([]byte, n)
+	if _, err := io.ReadFull(f, key); err != nil {
+		return nil, errCorrupt
+	}
+
+	return &item{
+		expiration: int64(binary.BigEndian.Uint64(h[1:])),
+		key:        string(key),
+	}, nil
+}
+
+// read returns the item in the file for the key. A file that contains
+// another key, which only happens if two keys has the same hash, is
+// treated as a missing item.
+func (s *Store) read(key string) (*item, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	i, err := readHeader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.key != key {
+		return nil, store.ErrNotFound
+	}
+
+	if store.Expired(i.expiration) {
+		return nil, store.ErrExpired
+	}
+
+	if i.value, err = io.ReadAll(f); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// write writes the item to a temporary file in the same directory
+// and renames it to the item file, so readers never see a partially
+// written file.
+func (s *Store) write(i *item) error {
+	path := s.path(i.key)
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, tmpPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(i.encode()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// lock creates the lock file for the key, it waits for the lock
+// file to be removed by the owner if it exists. Lock files older
+// than staleAge are removed since the owner has crashed.
+func (s *Store) lock(key string) (func(), error) {
+	path := s.path(key) + lockSuffix
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	wait := time.Millisecond
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > staleAge {
+			os.Remove(path)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.New("file: timeout waiting for lock on " + key)
+		}
+
+		time.Sleep(wait)
+
+		if wait < 50*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// janitor removes expired items at the configured interval
+// until the store is closed.
+func (s *Store) janitor() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Cleanup()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// entry represents a file in the directory.
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// walk calls fn for each item file in the directory. Temporary and
+// lock files are skipped, but removed if they are older than staleAge.
+func (s *Store) walk(fn func(entry) error) error {
+	dirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() || len(d.Name()) != 2 {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(s.dir, d.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			fi, err := f.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			path := filepath.Join(s.dir, d.Name(), f.Name())
+
+			if strings.HasPrefix(f.Name(), tmpPrefix) || strings.HasSuffix(f.Name(), lockSuffix) {
+				if time.Since(fi.ModTime()) > staleAge {
+					os.Remove(path)
+				}
+
+				continue
+			}
+
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+
+			if err := fn(entry{path: path, size: fi.Size(), modTime: fi.ModTime()}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// header reads the header of the file at the path,
+// nil is returned if the file has been removed.
+func header(path string) (*item, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return readHeader(f)
+}
+
+// remove removes the file, a file that is already removed is ignored.
+func remove(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Cleanup will remove all expired and corrupt items from the store. If
+// the store has a max size the least recently written items are then
+// removed until the size of the items is below the max size.
+func (s *Store) Cleanup() error {
+	var (
+		entries []entry
+		size    int64
+	)
+
+	err := s.walk(func(e entry) error {
+		i, err := header(e.path)
+		if err == errCorrupt || (i != nil && store.Expired(i.expiration)) {
+			return remove(e.path)
+		}
+
+		if err != nil || i == nil {
+			return err
+		}
+
+		entries = append(entries, e)
+		size += e.size
+
+		return nil
+	})
+
+	if err != nil || s.maxBytes <= 0 || size <= s.maxBytes {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for _, e := range entries {
+		if size <= s.maxBytes {
+			break
+		}
+
+		if err := remove(e.path); err != nil {
+			return err
+		}
+
+		size -= e.size
+	}
+
+	return nil
+}
+
+// Close store.
+func (s *Store) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+
+	return nil
+}
+
+// Decrement will decrement a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Decrement(key string, n ...int64) (int64, error) {
+	return s.increment(key, -store.Delta(n...))
+}
+
+// Flush remove all items from the cache by removing
+// all sub directories in the directory.
+func (s *Store) Flush() error {
+	dirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() || len(d.Name()) != 2 {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(s.dir, d.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FlushPrefix will remove all items with keys that starts with the
+// prefix. The key is stored in each file since the file names are
+// hashed, so all file headers has to be read.
+func (s *Store) FlushPrefix(prefix string) error {
+	return s.walk(func(e entry) error {
+		i, err := header(e.path)
+		if err != nil || i == nil || !strings.HasPrefix(i.key, prefix) {
+			return err
+		}
+
+		return remove(e.path)
+	})
+}
+
+// Get will retrieve a item from the cache.
+func (s *Store) Get(key string) (interface{}, error) {
+	var v interface{}
+
+	if err := s.Result(key, &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Increment will increment a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Increment(key string, n ...int64) (int64, error) {
+	return s.increment(key, store.Delta(n...))
+}
+
+// increment reads and writes the number while the lock file for
+// the key is held, so numbers can be modified by many processes.
+// The expiration of the item is kept.
+func (s *Store) increment(key string, n int64) (int64, error) {
+	unlock, err := s.lock(key)
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	var v int64
+
+	i, err := s.read(key)
+	if errors.Is(err, store.ErrNotFound) {
+		i = &item{key: key}
+	} else if err != nil {
+		return 0, err
+	} else if err := store.Unmarshal(i.value, &v); err != nil {
+		return 0, err
+	}
+
+	v += n
+
+	if i.value, err = store.MarshalWith(s.codec, v); err != nil {
+		return 0, err
+	}
+
+	return v, s.write(i)
+}
+
+// Len will retrieve the number of items in the cache that has not expired.
+func (s *Store) Len() (int, error) {
+	n := 0
+
+	err := s.Scan("", func(string) bool {
+		n++
+		return true
+	})
+
+	return n, err
+}
+
+// Number will retrieve a number from the cache.
+func (s *Store) Number(key string) (int64, error) {
+	var v int64
+
+	if err := s.Result(key, &v); err != nil {
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// Remove will remove a item from the cache, store.ErrNotFound is
+// returned if the item don't exists. Expired items are removed but
+// store.ErrExpired is returned.
+func (s *Store) Remove(key string) error {
+	_, err := s.read(key)
+	if err != nil && !errors.Is(err, store.ErrExpired) {
+		return err
+	}
+
+	if rerr := os.Remove(s.path(key)); errors.Is(rerr, fs.ErrNotExist) {
+		return store.ErrNotFound
+	} else if rerr != nil {
+		return rerr
+	}
+
+	return err
+}
+
+// Result will retrieve a item from the cache and stores the
+// result in the value pointed to by value.
+func (s *Store) Result(key string, value interface{}) error {
+	i, err := s.read(key)
+	if err != nil {
+		return err
+	}
+
+	return store.Unmarshal(i.value, value)
+}
+
+// Scan will call fn for each key in the cache that starts with the
+// prefix. Keys are not sorted and a key may be missed or passed to
+// fn if it's written or removed during the scan.
+func (s *Store) Scan(prefix string, fn func(string) bool) error {
+	err := s.walk(func(e entry) error {
+		i, err := header(e.path)
+		if err == errCorrupt || i == nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(i.key, prefix) || store.Expired(i.expiration) {
+			return nil
+		}
+
+		if !fn(i.key) {
+			return errStop
+		}
+
+		return nil
+	})
+
+	if err == errStop {
+		return nil
+	}
+
+	return err
+}
+
+// Set will store a item in the cache.
+func (s *Store) Set(key string, value interface{}, expiration time.Duration) error {
+	buf, err := store.MarshalWith(s.codec, value)
+	if err != nil {
+		return err
+	}
+
+	return s.write(&item{
+		expiration: store.ExpiresAt(expiration),
+		key:        key,
+		value:      buf,
+	})
+}
@@ -0,0 +1,304 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+func newStore(t *testing.T, opts ...Option) store.Store {
+	c, err := NewStore(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func TestStore(t *testing.T) {
+	c := newStore(t)
+
+	values := []interface{}{
+		"go",
+		true,
+		[]string{"abc"},
+		1,
+		1.2,
+		[]int{1, 2, 3},
+		uint64(3),
+		map[string]interface{}{"name": "go"},
+	}
+
+	for _, v := range values {
+		if err := c.Set("value", v, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if r, err := c.Get("value"); err != nil || !reflect.DeepEqual(r, v) {
+			t.Fatalf("%v does not match the expected value: %v (%v)", r, v, err)
+		}
+
+		if err := c.Remove("value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStorePath(t *testing.T) {
+	c := newStore(t)
+	s := c.(*Store)
+
+	if err := c.Set("name", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	path := s.path("name")
+	rel, _ := filepath.Rel(s.dir, path)
+	parts := strings.Split(rel, string(filepath.Separator))
+
+	if len(parts) != 2 || len(parts[1]) != 40 || parts[0] != parts[1][:2] {
+		t.Fatalf("Unexpected path %s", rel)
+	}
+
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name() != parts[1] {
+		t.Fatalf("Expected only the item file, got %v", files)
+	}
+}
+
+func TestStoreNotFound(t *testing.T) {
+	c := newStore(t)
+
+	if _, err := c.Get("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if _, err := c.Number("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Remove("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreCorrupt(t *testing.T) {
+	c := newStore(t)
+	s := c.(*Store)
+
+	if err := c.Set("key", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := []byte{version, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 'k'}
+	if err := os.WriteFile(s.path("key"), buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("key"); !errors.Is(err, errCorrupt) {
+		t.Fatalf("Expected errCorrupt, got %v", err)
+	}
+}
+
+func TestStoreExpired(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c := newStore(t)
+
+	if err := c.Set("ttl", "go", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Get("ttl"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	now = now.Add(time.Second)
+
+	if _, err := c.Get("ttl"); !errors.Is(err, store.ErrExpired) {
+		t.Fatalf("Expected store.ErrExpired, got %v", err)
+	}
+
+	if err := c.Remove("ttl"); !errors.Is(err, store.ErrExpired) {
+		t.Fatalf("Expected store.ErrExpired, got %v", err)
+	}
+
+	if _, err := os.Stat(c.(*Store).path("ttl")); !os.IsNotExist(err) {
+		t.Fatalf("Expected expired file to be removed, got %v", err)
+	}
+}
+
+func TestStoreIncrement(t *testing.T) {
+	c := newStore(t)
+
+	if v, err := c.Increment("number"); err != nil || v != 1 {
+		t.Fatalf("Expected 1, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Increment("number", 5); err != nil || v != 6 {
+		t.Fatalf("Expected 6, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number", 2); err != nil || v != 4 {
+		t.Fatalf("Expected 4, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Number("number"); err != nil || v != 4 {
+		t.Fatalf("Expected 4, got %d (%v)", v, err)
+	}
+}
+
+func TestStoreIncrementConcurrent(t *testing.T) {
+	dir := t.TempDir()
+
+	// Two stores in the same directory acts as two processes.
+	var stores []store.Store
+	for i := 0; i < 2; i++ {
+		c, err := NewStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer c.Close()
+
+		stores = append(stores, c)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(c store.Store) {
+			defer wg.Done()
+
+			if _, err := c.Increment("number"); err != nil {
+				t.Error(err)
+			}
+		}(stores[i%2])
+	}
+
+	wg.Wait()
+
+	if v, err := stores[0].Number("number"); err != nil || v != 50 {
+		t.Fatalf("Expected 50, got %d (%v)", v, err)
+	}
+}
+
+func TestStoreFlush(t *testing.T) {
+	c := newStore(t)
+
+	for _, k := range []string{"a:1", "a:2", "ab", "b:1"} {
+		if err := c.Set(k, k, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.(store.PrefixStore).FlushPrefix("a:"); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	err := c.(store.ScanStore).Scan("", func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(keys)
+
+	if !reflect.DeepEqual(keys, []string{"ab", "b:1"}) {
+		t.Fatalf("Expected [ab b:1], got %v", keys)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := c.(store.ScanStore).Len(); err != nil || n != 0 {
+		t.Fatalf("Expected 0 items, got %d (%v)", n, err)
+	}
+
+	if err := c.Set("a", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreCleanup(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c := newStore(t, WithMaxBytes(1))
+	s := c.(*Store)
+
+	if err := c.Set("expired", "go", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	value := strings.Repeat("a", 200)
+	modTime := time.Now().Add(-time.Hour)
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key:%d", i)
+
+		if err := c.Set(key, value, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		// The oldest files are removed first.
+		mt := modTime.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(s.path(key), mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fi, err := os.Stat(s.path("key:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for the four most recently written items.
+	s.maxBytes = 4 * fi.Size()
+
+	now = now.Add(time.Minute)
+
+	if err := s.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(s.path("expired")); !os.IsNotExist(err) {
+		t.Fatalf("Expected expired file to be removed, got %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := c.Get(fmt.Sprintf("key:%d", i))
+
+		if i < 6 && !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected key:%d to be removed, got %v", i, err)
+		}
+
+		if i >= 6 && err != nil {
+			t.Fatalf("Expected key:%d to exist, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package file
+
+import (
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// Option configures a file store.
+type Option func(*Store)
+
+// WithCleanupInterval will start a goroutine that calls Cleanup
+// at the given interval. The goroutine is stopped when the store
+// is closed.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.interval = interval
+	}
+}
+
+// WithCodec sets the codec that is used to encode values.
+func WithCodec(c store.Codec) Option {
+	return func(s *Store) {
+		s.codec = c
+	}
+}
+
+// WithMaxBytes sets the max size of all files in the store, the least
+// recently written items are removed by Cleanup when it's exceeded.
+func WithMaxBytes(n int64) Option {
+	return func(s *Store) {
+		s.maxBytes = n
+	}
+}
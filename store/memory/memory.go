@@ -1,7 +1,8 @@
 package memory
 
 import (
-	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,45 +11,223 @@ import (
 
 // Store represents the redis cache store.
 type Store struct {
-	items map[string]store.Item
-	mu    sync.RWMutex
+	shards     []*shard
+	interval   time.Duration
+	onEvicted  func(string, interface{})
+	codec      store.Codec
+	maxEntries int
+	maxBytes   int64
+	newPolicy  func(int) policy
+	stop       chan struct{}
+	once       sync.Once
+}
+
+// eviction represents a item that has been evicted from the store.
+type eviction struct {
+	key   string
+	value interface{}
 }
 
 // NewStore will create a new redis store with the given options.
-func NewStore() store.Store {
-	return &Store{
-		items: make(map[string]store.Item),
-		mu:    sync.RWMutex{},
+func NewStore(opts ...Option) store.Store {
+	s := &Store{
+		shards: make([]*shard, 1),
+		stop:   make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.maxEntries <= 0 && s.maxBytes <= 0 {
+		s.newPolicy = nil
+	} else if s.newPolicy == nil {
+		s.newPolicy = newLRU
+	}
+
+	// Each shard must get a part of the limits, since
+	// a shard without limits would be unbounded.
+	if s.maxEntries > 0 && len(s.shards) > s.maxEntries {
+		s.shards = make([]*shard, s.maxEntries)
+	}
+
+	if s.maxBytes > 0 && int64(len(s.shards)) > s.maxBytes {
+		s.shards = make([]*shard, s.maxBytes)
+	}
+
+	n := len(s.shards)
+
+	for i := range s.shards {
+		// Each shard gets its own part of the limits, the remainder
+		// is spread over the first shards so they add up to the limits.
+		maxEntries := split(int64(s.maxEntries), n, i)
+		maxBytes := split(s.maxBytes, n, i)
+
+		var newPolicy func() policy
+		if s.newPolicy != nil {
+			newPolicy = func() policy {
+				return s.newPolicy(int(maxEntries))
+			}
+		}
+
+		s.shards[i] = newShard(int(maxEntries), maxBytes, newPolicy)
+	}
+
+	if s.interval > 0 {
+		go s.janitor()
+	}
+
+	return s
+}
+
+// split returns the part of the limit for shard i of n,
+// zero is returned if there is no limit.
+func split(limit int64, n, i int) int64 {
+	if limit <= 0 {
+		return 0
+	}
+
+	part := limit / int64(n)
+	if int64(i) < limit%int64(n) {
+		part++
+	}
+
+	return part
+}
+
+// shard returns the shard for the given key.
+func (s *Store) shard(key string) *shard {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+
+	return s.shards[hash(key)%uint64(len(s.shards))]
+}
+
+// janitor removes expired items at the configured interval
+// until the store is closed.
+func (s *Store) janitor() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.DeleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// DeleteExpired will remove all expired items from the store.
+func (s *Store) DeleteExpired() {
+	for _, sh := range s.shards {
+		s.evicted(sh.deleteExpired())
+	}
+}
+
+// evicted calls the evicted callback for the given evictions,
+// it should be called without holding the lock.
+func (s *Store) evicted(evicted []eviction) {
+	if s.onEvicted == nil {
+		return
+	}
+
+	for _, e := range evicted {
+		s.onEvicted(e.key, e.value)
 	}
 }
 
 func (s *Store) setItem(key string, i store.Item) {
-	s.mu.Lock()
-	s.items[key] = i
-	s.mu.Unlock()
+	sh := s.shard(key)
+
+	sh.mu.Lock()
+	evicted := sh.put(key, i)
+	sh.mu.Unlock()
+
+	s.evicted(evicted)
 }
 
-func (s *Store) item(key string) (store.Item, error) {
-	s.mu.Lock()
-	i, ok := s.items[key]
-	if !ok || i.Expired() {
-		s.mu.Unlock()
-		return store.Item{}, fmt.Errorf("Item %s not found", key)
+// setIf stores the item if fn returns true for the current item, ok is
+// false if the item don't exists or has expired. The check and the write
+// is done under the same lock.
+func (s *Store) setIf(key string, value interface{}, expiration time.Duration, fn func(i store.Item, ok bool) bool) (bool, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+
+	i, ok := sh.items[key]
+	if !fn(i, ok && !i.Expired()) {
+		sh.mu.Unlock()
+		return false, nil
 	}
-	s.mu.Unlock()
-	return i, nil
+
+	evicted := sh.put(key, store.Item{
+		Object:     value,
+		Expiration: store.ExpiresAt(expiration),
+	})
+	sh.mu.Unlock()
+
+	s.evicted(evicted)
+
+	return true, nil
+}
+
+func (s *Store) item(key string) (store.Item, error) {
+	return s.shard(key).item(key)
+}
+
+// Add will store a item in the cache only if the key don't exists.
+func (s *Store) Add(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return s.setIf(key, value, expiration, func(i store.Item, ok bool) bool {
+		return !ok
+	})
 }
 
 // Close store.
 func (s *Store) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+
 	return nil
 }
 
+// CompareAndSwap will store the new value in the cache only if
+// the current value is deeply equal to the old value.
+func (s *Store) CompareAndSwap(key string, old, new interface{}, expiration time.Duration) (bool, error) {
+	return s.setIf(key, new, expiration, func(i store.Item, ok bool) bool {
+		return ok && reflect.DeepEqual(i.Object, old)
+	})
+}
+
+// Decrement will decrement a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Decrement(key string, n ...int64) (int64, error) {
+	return s.increment(key, -store.Delta(n...))
+}
+
 // Flush remove all items from the cache.
 func (s *Store) Flush() error {
-	s.mu.Lock()
-	s.items = make(map[string]store.Item)
-	s.mu.Unlock()
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.reset()
+		sh.mu.Unlock()
+	}
+	return nil
+}
+
+// FlushPrefix will remove all items with keys that starts with the prefix.
+func (s *Store) FlushPrefix(prefix string) error {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for k := range sh.items {
+			if strings.HasPrefix(k, prefix) {
+				sh.delete(k)
+			}
+		}
+		sh.mu.Unlock()
+	}
 	return nil
 }
 
@@ -62,22 +241,97 @@ func (s *Store) Get(key string) (interface{}, error) {
 	return i.Object, nil
 }
 
+// Increment will increment a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Increment(key string, n ...int64) (int64, error) {
+	return s.increment(key, store.Delta(n...))
+}
+
+func (s *Store) increment(key string, n int64) (int64, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+
+	i, ok := sh.items[key]
+	if !ok || i.Expired() {
+		i = store.Item{Object: int64(0)}
+	}
+
+	v, err := store.Int64(i.Object)
+	if err != nil {
+		sh.mu.Unlock()
+		return 0, err
+	}
+
+	i.Object = v + n
+	evicted := sh.put(key, i)
+	sh.mu.Unlock()
+
+	s.evicted(evicted)
+
+	return v + n, nil
+}
+
+// Len will retrieve the number of items in the cache that has not expired.
+func (s *Store) Len() (int, error) {
+	n := 0
+
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, i := range sh.items {
+			if !i.Expired() {
+				n++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	return n, nil
+}
+
+// Number will retrieve a number from the cache.
+func (s *Store) Number(key string) (int64, error) {
+	i, err := s.item(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return store.Int64(i.Object)
+}
+
+// Persist will remove the expiration of a item in the cache.
+func (s *Store) Persist(key string) error {
+	return s.Touch(key, 0)
+}
+
 // Remove will remove a item from the cache.
 func (s *Store) Remove(key string) error {
-	s.mu.Lock()
+	sh := s.shard(key)
+	sh.mu.Lock()
 
-	i, ok := s.items[key]
-	if !ok || i.Expired() {
-		s.mu.Unlock()
-		return fmt.Errorf("Item %s not found", key)
+	i, ok := sh.items[key]
+	if !ok {
+		sh.mu.Unlock()
+		return store.ErrNotFound
+	}
+
+	if i.Expired() {
+		sh.mu.Unlock()
+		return store.ErrExpired
 	}
 
-	delete(s.items, key)
-	s.mu.Unlock()
+	sh.delete(key)
+	sh.mu.Unlock()
 
 	return nil
 }
 
+// Replace will store a item in the cache only if the key exists.
+func (s *Store) Replace(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return s.setIf(key, value, expiration, func(i store.Item, ok bool) bool {
+		return ok
+	})
+}
+
 // Result will retrieve a item from the cache and stores the
 // result in the value pointed to by value.
 func (s *Store) Result(key string, value interface{}) error {
@@ -86,19 +340,74 @@ func (s *Store) Result(key string, value interface{}) error {
 		return err
 	}
 
-	buf, err := store.Marshal(i.Object)
+	buf, err := store.MarshalWith(s.codec, i.Object)
 	if err != nil {
 		return err
 	}
 
-	return store.Unmarshal(buf, &value)
+	return store.Unmarshal(buf, value)
+}
+
+// Scan will call fn for each key in the cache that starts with the
+// prefix. It works on a snapshot of the keys so fn can modify the store.
+func (s *Store) Scan(prefix string, fn func(string) bool) error {
+	for _, sh := range s.shards {
+		var keys []string
+
+		sh.mu.RLock()
+		for k, i := range sh.items {
+			if strings.HasPrefix(k, prefix) && !i.Expired() {
+				keys = append(keys, k)
+			}
+		}
+		sh.mu.RUnlock()
+
+		for _, k := range keys {
+			if !fn(k) {
+				return nil
+			}
+		}
+	}
+
+	return nil
 }
 
 // Set will store a item in the cache.
 func (s *Store) Set(key string, value interface{}, expiration time.Duration) error {
 	s.setItem(key, store.Item{
 		Object:     value,
-		Expiration: expiration,
+		Expiration: store.ExpiresAt(expiration),
 	})
 	return nil
 }
+
+// Touch will set a new expiration on a item in the cache.
+func (s *Store) Touch(key string, ttl time.Duration) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	i, ok := sh.items[key]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if i.Expired() {
+		return store.ErrExpired
+	}
+
+	i.Expiration = store.ExpiresAt(ttl)
+	sh.items[key] = i
+
+	return nil
+}
+
+// TTL will retrieve the remaining time to live of a item in the cache.
+func (s *Store) TTL(key string) (time.Duration, error) {
+	i, err := s.item(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return store.TTL(i.Expiration), nil
+}
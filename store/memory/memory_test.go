@@ -1,9 +1,15 @@
 package memory
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
 )
 
 func TestStore(t *testing.T) {
@@ -61,12 +67,18 @@ func TestStoreExpired(t *testing.T) {
 
 	defer c.Close()
 
-	if err := c.Set("test", "test", 1); err != nil {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	if err := c.Set("test", "test", time.Second); err != nil {
 		t.Fatal(err)
 	}
 
-	if _, err := c.Get("test"); err == nil {
-		t.Error("Expected error, got nil")
+	now = now.Add(time.Second)
+
+	if _, err := c.Get("test"); !errors.Is(err, store.ErrExpired) {
+		t.Errorf("Expected store.ErrExpired, got %v", err)
 	}
 }
 
@@ -94,3 +106,644 @@ func TestStoreStruct(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestStoreIncrement(t *testing.T) {
+	c := NewStore()
+
+	defer c.Close()
+
+	c.Remove("number")
+
+	if v, err := c.Increment("number"); err != nil || v != 1 {
+		t.Fatalf("Expected 1, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Increment("number", 5); err != nil || v != 6 {
+		t.Fatalf("Expected 6, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number", 2); err != nil || v != 4 {
+		t.Fatalf("Expected 4, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Number("number"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+
+	if err := c.Remove("number"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreIncrementConcurrent(t *testing.T) {
+	c := NewStore()
+
+	defer c.Close()
+
+	c.Remove("number")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := c.Increment("number"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if v, err := c.Number("number"); err != nil || v != 50 {
+		t.Fatalf("Expected 50, got %d (%v)", v, err)
+	}
+
+	if err := c.Remove("number"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreNotFound(t *testing.T) {
+	c := NewStore()
+
+	defer c.Close()
+
+	if _, err := c.Get("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	var v string
+	if err := c.Result("missing", &v); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if _, err := c.Number("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreCleanup(t *testing.T) {
+	evicted := make(chan string, 1)
+
+	c := NewStore(
+		WithCleanupInterval(10*time.Millisecond),
+		WithOnEvicted(func(key string, value interface{}) {
+			evicted <- key
+		}),
+	)
+
+	defer c.Close()
+
+	if err := c.Set("test", "test", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case key := <-evicted:
+		if key != "test" {
+			t.Fatalf("%s does not match the expected value: test", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected item to be evicted")
+	}
+
+	s := c.(*Store).shards[0]
+	s.mu.RLock()
+	n := len(s.items)
+	s.mu.RUnlock()
+
+	if n != 0 {
+		t.Fatalf("Expected no items, got %d", n)
+	}
+}
+
+func TestStoreEvictedOverwrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(store.Store) error
+	}{
+		{"Set", func(c store.Store) error { return c.Set("number", 5, 0) }},
+		{"Increment", func(c store.Store) error {
+			_, err := c.Increment("number")
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Now()
+			store.Now = func() time.Time { return now }
+			defer func() { store.Now = time.Now }()
+
+			var evicted []interface{}
+
+			c := NewStore(WithOnEvicted(func(key string, value interface{}) {
+				evicted = append(evicted, value)
+			}))
+
+			defer c.Close()
+
+			if err := c.Set("number", 1, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			now = now.Add(time.Hour)
+
+			if err := test.write(c); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(evicted, []interface{}{1}) {
+				t.Fatalf("Expected the expired item to be evicted, got %v", evicted)
+			}
+		})
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c := NewStore()
+
+	defer c.Close()
+
+	tests := []time.Duration{
+		time.Millisecond,
+		100 * time.Millisecond,
+		time.Second,
+		2 * time.Hour,
+		48 * time.Hour,
+	}
+
+	for _, ttl := range tests {
+		now = time.Now()
+
+		if err := c.Set("ttl", "go", ttl); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(ttl - time.Nanosecond)
+		if v, err := c.Get("ttl"); err != nil || v != "go" {
+			t.Fatalf("Expected item with ttl %s to exist, got %v (%v)", ttl, v, err)
+		}
+
+		now = now.Add(time.Nanosecond)
+		if _, err := c.Get("ttl"); !errors.Is(err, store.ErrExpired) {
+			t.Fatalf("Expected store.ErrExpired for ttl %s, got %v", ttl, err)
+		}
+	}
+}
+
+func TestStoreIncrementTTL(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c := NewStore()
+
+	defer c.Close()
+
+	if err := c.Set("number", 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Increment("number"); err != nil || v != 2 {
+		t.Fatalf("Expected 2, got %d (%v)", v, err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := c.Number("number"); !errors.Is(err, store.ErrExpired) {
+		t.Fatalf("Expected store.ErrExpired, got %v", err)
+	}
+}
+
+func TestStoreCodec(t *testing.T) {
+	c := NewStore(WithCodec(store.JSONCodec))
+
+	defer c.Close()
+
+	if err := c.Set("codec", 42, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var v int64
+	if err := c.Result("codec", &v); err != nil || v != 42 {
+		t.Fatalf("Expected 42, got %d (%v)", v, err)
+	}
+}
+
+func TestStoreMaxEntries(t *testing.T) {
+	var evicted []string
+
+	c := NewStore(
+		WithMaxEntries(2),
+		WithOnEvicted(func(key string, value interface{}) {
+			evicted = append(evicted, key)
+		}),
+	)
+
+	defer c.Close()
+
+	for _, k := range []string{"a", "b"} {
+		if err := c.Set(k, k, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Set("c", "c", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("b"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	for _, k := range []string{"a", "c"} {
+		if _, err := c.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !reflect.DeepEqual(evicted, []string{"b"}) {
+		t.Fatalf("%v does not match the expected value: [b]", evicted)
+	}
+}
+
+func TestStoreMaxBytes(t *testing.T) {
+	c := NewStore(WithMaxBytes(10))
+
+	defer c.Close()
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := c.Set(k, "abc", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Get("a"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Remove("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Set("d", "abc", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"c", "d"} {
+		if _, err := c.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s := c.(*Store).shards[0]; s.bytes != 8 {
+		t.Fatalf("Expected 8 bytes, got %d", s.bytes)
+	}
+}
+
+func TestStoreShards(t *testing.T) {
+	c := NewStore(WithShards(8), WithMaxEntries(80))
+
+	defer c.Close()
+
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("key-%d", i)
+		if err := c.Set(k, i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := 0
+	for _, s := range c.(*Store).shards {
+		if len(s.items) > 10 {
+			t.Fatalf("Expected at most 10 items in shard, got %d", len(s.items))
+		}
+		n += len(s.items)
+	}
+
+	if n == 0 {
+		t.Fatal("Expected items in shards")
+	}
+
+	if v, err := c.Get("key-999"); err != nil || v != 999 {
+		t.Fatalf("Expected 999, got %v (%v)", v, err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("key-999"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreShardsLimit(t *testing.T) {
+	tests := []struct {
+		shards, maxEntries int
+	}{
+		{16, 10},
+		{64, 100},
+		{3, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d/%d", test.shards, test.maxEntries), func(t *testing.T) {
+			c := NewStore(WithShards(test.shards), WithMaxEntries(test.maxEntries))
+
+			defer c.Close()
+
+			for i := 0; i < test.maxEntries*10; i++ {
+				c.Set(fmt.Sprintf("key-%d", i), i, 0)
+			}
+
+			total := 0
+			for _, s := range c.(*Store).shards {
+				total += s.maxEntries
+			}
+
+			if total != test.maxEntries {
+				t.Fatalf("Expected shard limits to add up to %d, got %d", test.maxEntries, total)
+			}
+
+			if n, _ := c.(store.ScanStore).Len(); n > test.maxEntries {
+				t.Fatalf("Expected at most %d items, got %d", test.maxEntries, n)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	for _, k := range []string{"", "a", "key-999", "ünïcode"} {
+		h := fnv.New64a()
+		h.Write([]byte(k))
+
+		if v := hash(k); v != h.Sum64() {
+			t.Errorf("Expected %d for %q, got %d", h.Sum64(), k, v)
+		}
+	}
+
+	if n := testing.AllocsPerRun(100, func() { hash("key-999") }); n != 0 {
+		t.Fatalf("Expected no allocations, got %v", n)
+	}
+}
+
+func benchmarkStore(b *testing.B, opts ...Option) {
+	c := NewStore(opts...)
+
+	defer c.Close()
+
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		c.Set(keys[i], i, 0)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := keys[i%len(keys)]
+			if i%10 == 0 {
+				c.Set(k, i, 0)
+			} else {
+				c.Get(k)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkStore(b *testing.B) {
+	for _, n := range []int{1, 16, 64} {
+		b.Run(fmt.Sprintf("Shards%d", n), func(b *testing.B) {
+			benchmarkStore(b, WithShards(n))
+		})
+
+		b.Run(fmt.Sprintf("Shards%dLRU", n), func(b *testing.B) {
+			benchmarkStore(b, WithShards(n), WithMaxEntries(2048))
+		})
+	}
+}
+
+func TestStoreFlushPrefix(t *testing.T) {
+	c := NewStore()
+
+	defer c.Close()
+
+	for _, k := range []string{"a:1", "a:2", "ab", "b:1"} {
+		if err := c.Set(k, k, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.(store.PrefixStore).FlushPrefix("a:"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a:1", "a:2"} {
+		if _, err := c.Get(k); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected store.ErrNotFound for %s, got %v", k, err)
+		}
+	}
+
+	for _, k := range []string{"ab", "b:1"} {
+		if v, err := c.Get(k); err != nil || v != k {
+			t.Fatalf("Expected %s, got %v (%v)", k, v, err)
+		}
+
+		c.Remove(k)
+	}
+}
+
+func TestStoreConditional(t *testing.T) {
+	c := NewStore()
+
+	defer c.Close()
+
+	s := c.(store.ConditionalStore)
+
+	c.Remove("cond")
+
+	if ok, err := s.Replace("cond", "a", 0); err != nil || ok {
+		t.Fatalf("Expected replace of missing key to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Add("cond", "a", 0); err != nil || !ok {
+		t.Fatalf("Expected add to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Add("cond", "b", 0); err != nil || ok {
+		t.Fatalf("Expected add of existing key to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Replace("cond", "b", 0); err != nil || !ok {
+		t.Fatalf("Expected replace to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.CompareAndSwap("cond", "a", "c", 0); err != nil || ok {
+		t.Fatalf("Expected compare and swap with wrong value to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.CompareAndSwap("cond", "b", "c", time.Hour); err != nil || !ok {
+		t.Fatalf("Expected compare and swap to succeed, got %v (%v)", ok, err)
+	}
+
+	if v, err := c.Get("cond"); err != nil || v != "c" {
+		t.Fatalf("Expected c, got %v (%v)", v, err)
+	}
+
+	if err := c.Remove("cond"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreTouch(t *testing.T) {
+	c := NewStore()
+
+	defer c.Close()
+
+	s := c.(store.TTLStore)
+
+	if _, err := s.TTL("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := s.Touch("missing", time.Hour); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := s.Persist("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Set("touch", "go", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("Expected ttl of at most a minute, got %s (%v)", ttl, err)
+	}
+
+	if err := s.Touch("touch", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl <= time.Minute || ttl > time.Hour {
+		t.Fatalf("Expected ttl of at most a hour, got %s (%v)", ttl, err)
+	}
+
+	if err := s.Persist("touch"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl != 0 {
+		t.Fatalf("Expected no ttl, got %s (%v)", ttl, err)
+	}
+
+	if v, err := c.Get("touch"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	if err := c.Remove("touch"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreScan(t *testing.T) {
+	c := NewStore()
+
+	defer c.Close()
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2500; i++ {
+		if err := c.Set(fmt.Sprintf("scan:%04d", i), i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Set("other", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	s := c.(store.ScanStore)
+
+	keys := make(map[string]bool)
+	err := s.Scan("scan:", func(key string) bool {
+		keys[key] = true
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2500 || keys["other"] {
+		t.Fatalf("Expected 2500 keys, got %d", len(keys))
+	}
+
+	n := 0
+	err = s.Scan("", func(key string) bool {
+		n++
+		return n < 10
+	})
+
+	if err != nil || n != 10 {
+		t.Fatalf("Expected scan to stop after 10 keys, got %d (%v)", n, err)
+	}
+
+	if n, err := s.Len(); err != nil || n != 2501 {
+		t.Fatalf("Expected 2501, got %d (%v)", n, err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreScanExpired(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	c := NewStore()
+
+	defer c.Close()
+
+	c.Set("a", "a", time.Second)
+	c.Set("b", "b", 0)
+
+	now = now.Add(time.Second)
+
+	var keys []string
+	c.(store.ScanStore).Scan("", func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	if !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Fatalf("%v does not match the expected value: [b]", keys)
+	}
+
+	if n, _ := c.(store.ScanStore).Len(); n != 1 {
+		t.Fatalf("Expected 1, got %d", n)
+	}
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// Option configures a memory store.
+type Option func(*Store)
+
+// WithCleanupInterval will start a goroutine that removes expired
+// items from the store at the given interval. The goroutine is
+// stopped when the store is closed.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.interval = interval
+	}
+}
+
+// WithOnEvicted sets a function that is called with the key and
+// value of a item when it's evicted from the store because it has
+// expired or because the store has exceeded its limits.
+func WithOnEvicted(fn func(string, interface{})) Option {
+	return func(s *Store) {
+		s.onEvicted = fn
+	}
+}
+
+// WithCodec sets the codec that is used when values are copied
+// into the value passed to Result.
+func WithCodec(c store.Codec) Option {
+	return func(s *Store) {
+		s.codec = c
+	}
+}
+
+// WithMaxEntries limits the number of items in the store, the least
+// recently used item is evicted when the limit is exceeded.
+func WithMaxEntries(n int) Option {
+	return func(s *Store) {
+		s.maxEntries = n
+	}
+}
+
+// WithMaxBytes limits the estimated size in bytes of the keys and values
+// in the store, the least recently used item is evicted when the limit
+// is exceeded. Strings, byte slices and numbers are measured by their
+// length, other values by the length of their encoded form.
+func WithMaxBytes(n int64) Option {
+	return func(s *Store) {
+		s.maxBytes = n
+	}
+}
+
+// WithTinyLFU will use the W-TinyLFU policy instead of the least
+// recently used policy to decide which item to evict. It keeps a
+// frequency sketch of recently seen keys so keys that are only
+// used once, e.g. by a scan, can't push out keys that are used often.
+// The policy is sized by WithMaxEntries or for 10000 entries per
+// shard if the store is only limited by WithMaxBytes.
+func WithTinyLFU() Option {
+	return func(s *Store) {
+		s.newPolicy = newTinyLFU
+	}
+}
+
+// WithShards splits the store into n independently locked shards, keys
+// are hashed to a shard so concurrent calls for different keys don't
+// have to wait for each other. The limits set by WithMaxEntries and
+// WithMaxBytes are divided between the shards, so a item can be evicted
+// from a full shard before the store is full. The number of shards is
+// never larger than the max entries.
+func WithShards(n int) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.shards = make([]*shard, n)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"container/list"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// policy decides which item to evict when the store is full.
+type policy interface {
+	// add records a new key in the policy.
+	add(key string)
+	// access records a hit or update of a existing key.
+	access(key string)
+	// remove removes the key from the policy.
+	remove(key string)
+	// victim returns the key that should be evicted next.
+	victim() (string, bool)
+}
+
+// lru is a policy that evicts the least recently used key.
+type lru struct {
+	ll    *list.List
+	elems map[string]*list.Element
+}
+
+func newLRU(int) policy {
+	return &lru{
+		ll:    list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (p *lru) add(key string) {
+	p.elems[key] = p.ll.PushFront(key)
+}
+
+func (p *lru) access(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *lru) remove(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.ll.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lru) victim() (string, bool) {
+	e := p.ll.Back()
+	if e == nil {
+		return "", false
+	}
+
+	return e.Value.(string), true
+}
+
+// sizeOf returns the estimated size in bytes of a item.
+func sizeOf(key string, value interface{}) int64 {
+	size := int64(len(key))
+
+	switch v := value.(type) {
+	case nil:
+		return size
+	case string:
+		return size + int64(len(v))
+	case []byte:
+		return size + int64(len(v))
+	case bool, int8, uint8:
+		return size + 1
+	case int16, uint16:
+		return size + 2
+	case int32, uint32, float32:
+		return size + 4
+	case int, int64, uint, uint64, float64:
+		return size + 8
+	}
+
+	buf, err := store.Marshal(value)
+	if err != nil {
+		return size
+	}
+
+	return size + int64(len(buf))
+}
@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// trace returns a list of keys to access.
+type trace func(n int) []string
+
+// zipfTrace returns keys that follow a zipf distribution.
+func zipfTrace(n int) []string {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.01, 1, 100000)
+	keys := make([]string, n)
+
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", z.Uint64())
+	}
+
+	return keys
+}
+
+// scanTrace returns keys that follow a zipf distribution that is
+// interrupted by long scans over keys that are only used once.
+func scanTrace(n int) []string {
+	keys := zipfTrace(n)
+
+	for i := 0; i < len(keys); i += 10000 {
+		for j := i; j < i+5000 && j < len(keys); j++ {
+			keys[j] = fmt.Sprintf("scan-%d", j)
+		}
+	}
+
+	return keys
+}
+
+// hitRatio returns the hit ratio of a store for the given keys,
+// a missed key is added to the store.
+func hitRatio(opts []Option, keys []string) float64 {
+	s := NewStore(opts...)
+	defer s.Close()
+
+	hits := 0
+
+	for _, k := range keys {
+		if _, err := s.Get(k); err == nil {
+			hits++
+			continue
+		}
+
+		s.Set(k, k, 0)
+	}
+
+	return float64(hits) / float64(len(keys))
+}
+
+func TestPolicyLRU(t *testing.T) {
+	p := newLRU(0)
+
+	for _, k := range []string{"a", "b", "c"} {
+		p.add(k)
+	}
+
+	p.access("a")
+	p.remove("b")
+
+	if k, ok := p.victim(); !ok || k != "c" {
+		t.Fatalf("Expected c, got %s", k)
+	}
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(100)
+
+	for i := 0; i < 10; i++ {
+		s.increment("hot")
+	}
+
+	s.increment("cold")
+
+	if f := s.frequency("hot"); f != 10 {
+		t.Fatalf("Expected 10, got %d", f)
+	}
+
+	if f := s.frequency("cold"); f != 1 {
+		t.Fatalf("Expected 1, got %d", f)
+	}
+
+	s.reset()
+
+	if f := s.frequency("hot"); f != 5 {
+		t.Fatalf("Expected 5, got %d", f)
+	}
+}
+
+func TestPolicyTinyLFU(t *testing.T) {
+	c := NewStore(WithMaxEntries(200), WithTinyLFU())
+
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprintf("hot-%d", i)
+		c.Set(k, k, 0)
+	}
+
+	for j := 0; j < 5; j++ {
+		for i := 0; i < 100; i++ {
+			c.Get(fmt.Sprintf("hot-%d", i))
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("scan-%d", i)
+		c.Set(k, k, 0)
+	}
+
+	hits := 0
+	for i := 0; i < 100; i++ {
+		if _, err := c.Get(fmt.Sprintf("hot-%d", i)); err == nil {
+			hits++
+		}
+	}
+
+	if hits < 95 {
+		t.Fatalf("Expected at least 95 hot keys to survive the scan, got %d", hits)
+	}
+}
+
+func TestPolicyTinyLFUCandidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p policy)
+	}{
+		{"Remove", func(p policy) { p.remove("b") }},
+		{"Promote", func(p policy) { p.access("b") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The window holds one key, so b is the
+			// candidate when c pushes it out.
+			p := newTinyLFU(100)
+
+			for _, k := range []string{"a", "b", "c"} {
+				p.add(k)
+			}
+
+			tt.modify(p)
+
+			if k, ok := p.victim(); !ok || k != "a" {
+				t.Fatalf("Expected a, got %s", k)
+			}
+		})
+	}
+}
+
+func TestPolicyTinyLFUScan(t *testing.T) {
+	keys := scanTrace(200000)
+
+	lru := hitRatio([]Option{WithMaxEntries(1000)}, keys)
+	lfu := hitRatio([]Option{WithMaxEntries(1000), WithTinyLFU()}, keys)
+
+	if lfu <= lru {
+		t.Fatalf("Expected tinylfu hit ratio %.4f to be higher than lru hit ratio %.4f", lfu, lru)
+	}
+}
+
+func BenchmarkHitRatio(b *testing.B) {
+	traces := []struct {
+		name  string
+		trace trace
+	}{
+		{"Zipf", zipfTrace},
+		{"Scan", scanTrace},
+	}
+
+	policies := []struct {
+		name string
+		opts []Option
+	}{
+		{"LRU", []Option{WithMaxEntries(1000)}},
+		{"TinyLFU", []Option{WithMaxEntries(1000), WithTinyLFU()}},
+	}
+
+	for _, t := range traces {
+		keys := t.trace(200000)
+
+		for _, p := range policies {
+			b.Run(t.name+"/"+p.name, func(b *testing.B) {
+				var ratio float64
+
+				for i := 0; i < b.N; i++ {
+					ratio = hitRatio(p.opts, keys)
+				}
+
+				b.ReportMetric(ratio*100, "hit%")
+			})
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// shard is a independently locked part of the store.
+type shard struct {
+	items      map[string]store.Item
+	sizes      map[string]int64
+	bytes      int64
+	mu         sync.RWMutex
+	maxEntries int
+	maxBytes   int64
+	newPolicy  func() policy
+	policy     policy
+	// pmu guards the policy when a item is accessed under the read
+	// lock, writers holds the write lock so they don't need it.
+	pmu sync.Mutex
+}
+
+func newShard(maxEntries int, maxBytes int64, newPolicy func() policy) *shard {
+	s := &shard{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		newPolicy:  newPolicy,
+	}
+
+	s.reset()
+
+	return s
+}
+
+// FNV-1a constants, see hash/fnv.
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// hash returns the 64-bit FNV-1a hash of the key. It's the same as
+// hash/fnv but works on the string, so nothing is allocated.
+func hash(key string) uint64 {
+	h := uint64(offset64)
+
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+
+	return h
+}
+
+// reset removes all items from the shard, the lock must be held.
+func (s *shard) reset() {
+	s.items = make(map[string]store.Item)
+	s.sizes = make(map[string]int64)
+	s.bytes = 0
+
+	if s.newPolicy != nil {
+		s.policy = s.newPolicy()
+	}
+}
+
+// full returns true if the shard exceeds the max entries or max bytes.
+func (s *shard) full() bool {
+	return (s.maxEntries > 0 && len(s.items) > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes)
+}
+
+// item returns a item that has not expired.
+func (s *shard) item(key string) (store.Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.items[key]
+	if !ok {
+		return store.Item{}, store.ErrNotFound
+	}
+
+	if i.Expired() {
+		return store.Item{}, store.ErrExpired
+	}
+
+	if s.policy != nil {
+		s.pmu.Lock()
+		s.policy.access(key)
+		s.pmu.Unlock()
+	}
+
+	return i, nil
+}
+
+// put stores the item and evicts items until the shard is within its
+// limits, the lock must be held. A expired item that is replaced is
+// returned as evicted since it has not been removed yet.
+func (s *shard) put(key string, i store.Item) []eviction {
+	var evicted []eviction
+
+	old, ok := s.items[key]
+	if ok && old.Expired() {
+		evicted = append(evicted, eviction{key, old.Object})
+	}
+
+	s.items[key] = i
+
+	if s.policy == nil {
+		return evicted
+	}
+
+	if ok {
+		s.policy.access(key)
+	} else {
+		s.policy.add(key)
+	}
+
+	if s.maxBytes > 0 {
+		size := sizeOf(key, i.Object)
+		s.bytes += size - s.sizes[key]
+		s.sizes[key] = size
+	}
+
+	for s.full() {
+		k, ok := s.policy.victim()
+		if !ok {
+			break
+		}
+
+		evicted = append(evicted, eviction{k, s.items[k].Object})
+		s.delete(k)
+	}
+
+	return evicted
+}
+
+// delete removes the item from the shard, the lock must be held.
+func (s *shard) delete(key string) {
+	delete(s.items, key)
+
+	if s.policy != nil {
+		s.policy.remove(key)
+	}
+
+	if size, ok := s.sizes[key]; ok {
+		s.bytes -= size
+		delete(s.sizes, key)
+	}
+}
+
+// deleteExpired removes all expired items from the shard.
+func (s *shard) deleteExpired() []eviction {
+	var evicted []eviction
+
+	s.mu.Lock()
+	for k, i := range s.items {
+		if i.Expired() {
+			evicted = append(evicted, eviction{k, i.Object})
+			s.delete(k)
+		}
+	}
+	s.mu.Unlock()
+
+	return evicted
+}
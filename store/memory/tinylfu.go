@@ -0,0 +1,217 @@
+package memory
+
+import "container/list"
+
+// defaultCapacity is the number of entries the tinylfu policy
+// is sized for when the store has no max entries.
+const defaultCapacity = 10000
+
+// sketch is a count-min sketch with four bit counters that estimates
+// how often a key has been seen. The counters are halved after a
+// number of increments so old frequencies fade out.
+type sketch struct {
+	table   []uint64
+	mask    uint64
+	size    int
+	samples int
+}
+
+func newSketch(capacity int) *sketch {
+	width := 1
+	for width < capacity {
+		width <<= 1
+	}
+
+	return &sketch{
+		// Each uint64 holds sixteen counters.
+		table:   make([]uint64, width/4+1),
+		mask:    uint64(width*4 - 1),
+		samples: capacity * 10,
+	}
+}
+
+// index returns the table index and the bit offset of the
+// counter for the given hash and row.
+func (s *sketch) index(h uint64, row int) (int, uint) {
+	h = (h + uint64(row)*(h>>32|1)) * 0x9e3779b97f4a7c15
+	i := (h >> 16) & s.mask
+	return int(i / 16), uint(i%16) * 4
+}
+
+func (s *sketch) increment(key string) {
+	h := hash(key)
+	added := false
+
+	for row := 0; row < 4; row++ {
+		i, off := s.index(h, row)
+		if (s.table[i]>>off)&0xf < 15 {
+			s.table[i] += 1 << off
+			added = true
+		}
+	}
+
+	if added {
+		s.size++
+		if s.size >= s.samples {
+			s.reset()
+		}
+	}
+}
+
+func (s *sketch) frequency(key string) int {
+	h := hash(key)
+	min := 15
+
+	for row := 0; row < 4; row++ {
+		i, off := s.index(h, row)
+		if c := int((s.table[i] >> off) & 0xf); c < min {
+			min = c
+		}
+	}
+
+	return min
+}
+
+// reset halves all counters.
+func (s *sketch) reset() {
+	for i := range s.table {
+		s.table[i] = (s.table[i] >> 1) & 0x7777777777777777
+	}
+	s.size /= 2
+}
+
+const (
+	window = iota
+	probation
+	protected
+)
+
+// tinyEntry is the value of a element in one of the tinylfu lists.
+type tinyEntry struct {
+	key     string
+	segment int
+}
+
+// tinyLFU is a W-TinyLFU policy. New keys enter a small window lru and
+// are moved to the probation segment of the main lru when the window is
+// full. Keys that are accessed in probation are promoted to the protected
+// segment. When a item has to be evicted the frequency of the newest
+// probation key is compared with the oldest, so keys that are only seen
+// once can't push out keys that are used often.
+type tinyLFU struct {
+	sketch       *sketch
+	elems        map[string]*list.Element
+	lists        [3]*list.List
+	windowCap    int
+	protectedCap int
+	candidate    *list.Element
+}
+
+func newTinyLFU(capacity int) policy {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+
+	windowCap := capacity / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+
+	p := &tinyLFU{
+		sketch:       newSketch(capacity),
+		elems:        make(map[string]*list.Element),
+		windowCap:    windowCap,
+		protectedCap: (capacity - windowCap) * 8 / 10,
+	}
+
+	for i := range p.lists {
+		p.lists[i] = list.New()
+	}
+
+	return p
+}
+
+func (p *tinyLFU) add(key string) {
+	p.sketch.increment(key)
+	p.elems[key] = p.lists[window].PushFront(&tinyEntry{key, window})
+
+	if p.lists[window].Len() > p.windowCap {
+		p.candidate = p.move(p.lists[window].Back(), probation)
+	}
+}
+
+// move moves the element to the front of the given segment and
+// returns the new element, since the old one can't be reused.
+func (p *tinyLFU) move(e *list.Element, segment int) *list.Element {
+	if p.candidate == e {
+		p.candidate = nil
+	}
+
+	te := e.Value.(*tinyEntry)
+	p.lists[te.segment].Remove(e)
+	te.segment = segment
+	n := p.lists[segment].PushFront(te)
+	p.elems[te.key] = n
+
+	return n
+}
+
+func (p *tinyLFU) access(key string) {
+	p.sketch.increment(key)
+
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+
+	switch te := e.Value.(*tinyEntry); te.segment {
+	case window, protected:
+		p.lists[te.segment].MoveToFront(e)
+	case probation:
+		p.move(e, protected)
+
+		if p.lists[protected].Len() > p.protectedCap {
+			p.move(p.lists[protected].Back(), probation)
+		}
+	}
+}
+
+func (p *tinyLFU) remove(key string) {
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+
+	if p.candidate == e {
+		p.candidate = nil
+	}
+
+	p.lists[e.Value.(*tinyEntry).segment].Remove(e)
+	delete(p.elems, key)
+}
+
+func (p *tinyLFU) victim() (string, bool) {
+	if v := p.lists[probation].Back(); v != nil {
+		c := p.candidate
+		p.candidate = nil
+
+		if c != nil && c != v {
+			ck := c.Value.(*tinyEntry).key
+			vk := v.Value.(*tinyEntry).key
+
+			if p.sketch.frequency(ck) <= p.sketch.frequency(vk) {
+				return ck, true
+			}
+		}
+
+		return v.Value.(*tinyEntry).key, true
+	}
+
+	for _, segment := range []int{protected, window} {
+		if e := p.lists[segment].Back(); e != nil {
+			return e.Value.(*tinyEntry).key, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,261 @@
+package redis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+	goredis "github.com/go-redis/redis"
+)
+
+// Invalidation operations that are published on the channel.
+const (
+	opRemove = "del"
+	opFlush  = "flush"
+	opPrefix = "prefix"
+)
+
+// pingInterval is how long a subscription waits for a message
+// before it pings redis to check that the connection is alive.
+const pingInterval = 30 * time.Second
+
+// subscribeTimeout is how long a subscription waits for redis
+// to confirm the subscription.
+const subscribeTimeout = 5 * time.Second
+
+// ErrNoChannel is returned by Subscribe if the store has
+// no invalidation channel.
+var ErrNoChannel = errors.New("redis: no invalidation channel")
+
+// errClosed is returned by subscribe if the subscription is closed.
+var errClosed = errors.New("redis: subscription closed")
+
+// newID returns a random id that is used to ignore
+// invalidation messages published by the store itself.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// message returns the payload of a invalidation message.
+func message(id, op, key string) string {
+	return id + "|" + op + "|" + key
+}
+
+// parseMessage returns the parts of a invalidation message.
+func parseMessage(payload string) (id, op, key string, ok bool) {
+	parts := strings.SplitN(payload, "|", 3)
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+
+	return parts[0], parts[1], parts[2], true
+}
+
+// publish publishes a invalidation message for each key if the store
+// has a invalidation channel. Multiple messages are sent in one pipeline.
+func (s *Store) publish(c goredis.UniversalClient, op string, keys ...string) error {
+	if len(s.channel) == 0 || len(keys) == 0 {
+		return nil
+	}
+
+	if len(keys) == 1 {
+		return c.Process(goredis.NewIntCmd("publish", s.channel, message(s.id, op, keys[0])))
+	}
+
+	_, err := c.Pipelined(func(pipe goredis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Process(goredis.NewIntCmd("publish", s.channel, message(s.id, op, key)))
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// subscriber is implemented by the single, cluster and ring clients.
+type subscriber interface {
+	Subscribe(channels ...string) *goredis.PubSub
+}
+
+// Subscription evicts items from a local store when other
+// instances modifies the same keys in redis.
+type Subscription struct {
+	client  subscriber
+	channel string
+	id      string
+	local   store.Store
+
+	mu     sync.Mutex
+	pubsub *goredis.PubSub
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Subscribe will subscribe to the invalidation channel of the store and
+// evict items from the local store when they are modified in redis by
+// other instances. Messages published by the store itself are ignored
+// since the local store is expected to be updated by the caller, e.g.
+// a tiered store. The local store is flushed every time the channel is
+// subscribed since messages may have been missed while disconnected.
+func (s *Store) Subscribe(local store.Store) (*Subscription, error) {
+	if len(s.channel) == 0 {
+		return nil, ErrNoChannel
+	}
+
+	c, ok := s.client.(subscriber)
+	if !ok {
+		return nil, store.ErrNotSupported
+	}
+
+	sub := &Subscription{
+		client:  c,
+		channel: s.channel,
+		id:      s.id,
+		local:   local,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if err := sub.subscribe(); err != nil {
+		return nil, err
+	}
+
+	go sub.run()
+
+	return sub, nil
+}
+
+// subscribe creates a new pubsub connection and waits
+// for redis to confirm the subscription.
+func (sub *Subscription) subscribe() error {
+	// The subscribe command is sent again when the connection is
+	// used if it fails, so the error is found by the receive.
+	ps := sub.client.Subscribe(sub.channel)
+
+	if _, err := ps.ReceiveTimeout(subscribeTimeout); err != nil {
+		ps.Close()
+		return err
+	}
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	select {
+	case <-sub.stop:
+		ps.Close()
+		return errClosed
+	default:
+	}
+
+	sub.pubsub = ps
+	sub.local.Flush()
+
+	return nil
+}
+
+// run receives invalidation messages until the subscription is closed.
+// After a network error, or if redis don't answer a ping, the connection
+// is replaced with a new subscription which flushes the local store.
+func (sub *Subscription) run() {
+	defer close(sub.done)
+
+	pinged := false
+
+	for {
+		sub.mu.Lock()
+		ps := sub.pubsub
+		sub.mu.Unlock()
+
+		msg, err := ps.ReceiveTimeout(pingInterval)
+
+		select {
+		case <-sub.stop:
+			return
+		default:
+		}
+
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() && !pinged {
+				pinged = ps.Ping() == nil
+				continue
+			}
+
+			pinged = false
+
+			sub.mu.Lock()
+			sub.pubsub = nil
+			sub.mu.Unlock()
+			ps.Close()
+
+			for sub.subscribe() != nil {
+				select {
+				case <-sub.stop:
+					return
+				case <-time.After(time.Second):
+				}
+			}
+
+			continue
+		}
+
+		pinged = false
+
+		if m, ok := msg.(*goredis.Message); ok {
+			sub.handle(m.Payload)
+		}
+	}
+}
+
+// handle evicts the items in the message from the local store.
+func (sub *Subscription) handle(payload string) {
+	id, op, key, ok := parseMessage(payload)
+	if !ok || id == sub.id {
+		return
+	}
+
+	switch op {
+	case opRemove:
+		sub.local.Remove(key)
+	case opFlush:
+		sub.local.Flush()
+	case opPrefix:
+		if p, ok := sub.local.(store.PrefixStore); ok {
+			p.FlushPrefix(key)
+		} else {
+			sub.local.Flush()
+		}
+	}
+}
+
+// Close will stop the subscription.
+func (sub *Subscription) Close() error {
+	sub.mu.Lock()
+
+	select {
+	case <-sub.stop:
+		sub.mu.Unlock()
+		return nil
+	default:
+	}
+
+	close(sub.stop)
+
+	var err error
+	if sub.pubsub != nil {
+		err = sub.pubsub.Close()
+	}
+
+	sub.mu.Unlock()
+
+	<-sub.done
+
+	return err
+}
@@ -0,0 +1,22 @@
+package redis
+
+import "github.com/frozzare/go-cache/store"
+
+// Option configures a redis store.
+type Option func(*Store)
+
+// WithCodec sets the codec that is used to encode values.
+func WithCodec(c store.Codec) Option {
+	return func(s *Store) {
+		s.codec = c
+	}
+}
+
+// WithInvalidation sets the channel that invalidation messages are
+// published on when items are modified, so other instances can evict
+// them from their local stores, see Subscribe.
+func WithInvalidation(channel string) Option {
+	return func(s *Store) {
+		s.channel = channel
+	}
+}
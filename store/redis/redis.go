@@ -1,23 +1,75 @@
 package redis
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/frozzare/go-cache/store"
 	goredis "github.com/go-redis/redis"
 )
 
+// cas is a script that sets the key to the new value only if
+// the current value is equal to the old value.
+var cas = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// incr is a script that replaces the value with the new number as a
+// plain integer only if the current value is equal to the old value,
+// the expiration of the key is kept.
+var incr = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+local ttl = redis.call("PTTL", KEYS[1])
+redis.call("SET", KEYS[1], ARGV[2])
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
+`)
+
+// errStop is used to stop a scan.
+var errStop = errors.New("stop scan")
+
 // Options is a alias for the options for the redis client.
 type Options = goredis.Options
 
+// ClusterOptions is a alias for the options for the redis cluster client.
+type ClusterOptions = goredis.ClusterOptions
+
+// FailoverOptions is a alias for the options for the redis sentinel client.
+type FailoverOptions = goredis.FailoverOptions
+
+// RingOptions is a alias for the options for the redis ring client.
+type RingOptions = goredis.RingOptions
+
+// UniversalOptions is a alias for the options for the universal redis client.
+type UniversalOptions = goredis.UniversalOptions
+
 // Store represents the redis cache store.
 type Store struct {
-	client *goredis.Client
+	client  goredis.UniversalClient
+	codec   store.Codec
+	channel string
+	id      string
 }
 
 // NewStore will create a new redis store with the given options.
-func NewStore(o *Options) store.Store {
+func NewStore(o *Options, opts ...Option) store.Store {
 	if o == nil {
 		o = &Options{}
 	}
@@ -26,9 +78,119 @@ func NewStore(o *Options) store.Store {
 		o.Addr = "localhost:6379"
 	}
 
-	return &Store{
-		client: goredis.NewClient(o),
+	return newStore(goredis.NewClient(o), opts...)
+}
+
+// NewClusterStore will create a new redis store that uses
+// a redis cluster with the given options.
+func NewClusterStore(o *ClusterOptions, opts ...Option) store.Store {
+	return newStore(goredis.NewClusterClient(o), opts...)
+}
+
+// NewFailoverStore will create a new redis store that uses a
+// sentinel backed failover client with the given options.
+func NewFailoverStore(o *FailoverOptions, opts ...Option) store.Store {
+	return newStore(goredis.NewFailoverClient(o), opts...)
+}
+
+// NewRingStore will create a new redis store that shards
+// the keys between the redis servers in the ring options.
+func NewRingStore(o *RingOptions, opts ...Option) store.Store {
+	return newStore(goredis.NewRing(o), opts...)
+}
+
+// NewUniversalStore will create a new redis store that uses a
+// failover client if a master name is given, a cluster client if
+// more than one address is given and a single client otherwise.
+func NewUniversalStore(o *UniversalOptions, opts ...Option) store.Store {
+	if len(o.Addrs) == 0 {
+		o.Addrs = []string{"localhost:6379"}
+	}
+
+	return newStore(goredis.NewUniversalClient(o), opts...)
+}
+
+// newStore creates a store that uses the given client.
+func newStore(client goredis.UniversalClient, opts ...Option) *Store {
+	s := &Store{
+		client: client,
+		id:     newID(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// withContext returns a client that uses the given context,
+// a error is returned if the context is already done. Only
+// single clients can use a context, cluster and ring clients
+// are returned as they are. The redis client only keeps the
+// context for hooks, it's not used to cancel commands or set
+// deadlines, the client timeouts in the options are used for that.
+func (s *Store) withContext(ctx context.Context) (goredis.UniversalClient, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c, ok := s.client.(*goredis.Client); ok {
+		return c.WithContext(ctx), nil
+	}
+
+	return s.client, nil
+}
+
+// nodes calls fn with each master node in a cluster, each live shard
+// in a ring or the client itself, since commands like FLUSHDB and SCAN
+// only affects the node they are sent to. Cluster and ring nodes are
+// called concurrently.
+func (s *Store) nodes(fn func(*goredis.Client) error) error {
+	switch c := s.client.(type) {
+	case *goredis.ClusterClient:
+		return c.ForEachMaster(fn)
+	case *goredis.Ring:
+		return c.ForEachShard(fn)
+	case *goredis.Client:
+		return fn(c)
+	}
+
+	return store.ErrNotSupported
+}
+
+// del removes the keys with one DEL command for single clients and
+// with one DEL command per key in a pipeline for cluster and ring
+// clients since the keys can belong to different nodes.
+func (s *Store) del(keys []string) error {
+	if _, ok := s.client.(*goredis.Client); ok {
+		return s.client.Del(keys...).Err()
+	}
+
+	_, err := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(key)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Add will store a item in the cache only if the key don't exists.
+func (s *Store) Add(key string, value interface{}, expiration time.Duration) (bool, error) {
+	b, err := store.MarshalWith(s.codec, value)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.client.SetNX(key, b, expiration).Result()
+	if err != nil || !ok {
+		return ok, err
 	}
+
+	return true, s.publish(s.client, opRemove, key)
 }
 
 // Close store.
@@ -36,70 +198,589 @@ func (s *Store) Close() error {
 	return s.client.Close()
 }
 
+// CompareAndSwap will store the new value in the cache only if the
+// current value is equal to the encoded form of the old value.
+func (s *Store) CompareAndSwap(key string, old, new interface{}, expiration time.Duration) (bool, error) {
+	o, err := store.MarshalWith(s.codec, old)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := store.MarshalWith(s.codec, new)
+	if err != nil {
+		return false, err
+	}
+
+	ms := int64(expiration / time.Millisecond)
+	if expiration > 0 && ms == 0 {
+		ms = 1
+	}
+
+	v, err := cas.Run(s.client, []string{key}, o, n, ms).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if v != int64(1) {
+		return false, nil
+	}
+
+	return true, s.publish(s.client, opRemove, key)
+}
+
+// Decrement will decrement a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Decrement(key string, n ...int64) (int64, error) {
+	return s.DecrementContext(context.Background(), key, n...)
+}
+
+// DecrementContext will decrement a number in the cache with the given context.
+func (s *Store) DecrementContext(ctx context.Context, key string, n ...int64) (int64, error) {
+	c, err := s.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := incrBy(c, key, -store.Delta(n...))
+	if err != nil {
+		return 0, err
+	}
+
+	return v, s.publish(c, opRemove, key)
+}
+
 // Flush remove all items from the cache.
 func (s *Store) Flush() error {
-	return s.client.FlushDB().Err()
+	return s.FlushContext(context.Background())
+}
+
+// FlushContext remove all items from the cache with the given context.
+// All master nodes in a cluster and all shards in a ring are flushed.
+func (s *Store) FlushContext(ctx context.Context) error {
+	c, err := s.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.nodes(func(n *goredis.Client) error {
+		return n.WithContext(ctx).FlushDB().Err()
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.publish(c, opFlush, "")
+}
+
+// FlushPrefix will remove all items with keys that starts with the
+// prefix. The keys are found with SCAN so redis is never blocked by
+// a KEYS command and removed in batches.
+func (s *Store) FlushPrefix(prefix string) error {
+	err := s.scan(prefix, func(keys []string) error {
+		return s.del(keys)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.publish(s.client, opPrefix, prefix)
+}
+
+// scan calls fn with each batch of keys that starts with the prefix
+// until the SCAN cursor is exhausted or fn returns a error. Each node
+// is scanned by itself, but fn is never called concurrently and is not
+// called again on any node after it has returned a error.
+func (s *Store) scan(prefix string, fn func([]string) error) error {
+	var (
+		mu      sync.Mutex
+		stopped bool
+	)
+
+	return s.nodes(func(n *goredis.Client) error {
+		var cursor uint64
+
+		for {
+			mu.Lock()
+			done := stopped
+			mu.Unlock()
+
+			if done {
+				return nil
+			}
+
+			keys, next, err := n.Scan(cursor, escape(prefix)+"*", 100).Result()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) > 0 {
+				mu.Lock()
+				if stopped {
+					mu.Unlock()
+					return nil
+				}
+
+				err := fn(keys)
+				if err != nil {
+					stopped = true
+				}
+				mu.Unlock()
+
+				if err != nil {
+					return err
+				}
+			}
+
+			if next == 0 {
+				return nil
+			}
+
+			cursor = next
+		}
+	})
+}
+
+// escape escapes the glob characters in a key so
+// it can be used in a SCAN match pattern.
+func escape(key string) string {
+	var b strings.Builder
+
+	for _, r := range key {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Replace will store a item in the cache only if the key exists.
+func (s *Store) Replace(key string, value interface{}, expiration time.Duration) (bool, error) {
+	b, err := store.MarshalWith(s.codec, value)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.client.SetXX(key, b, expiration).Result()
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	return true, s.publish(s.client, opRemove, key)
 }
 
 // Result will retrieve a item from the cache and stores the
 // result in the value pointed to by value.
 func (s *Store) Result(key string, value interface{}) error {
-	b, err := s.client.Get(key).Bytes()
-	if len(b) == 0 {
-		return nil
+	return s.ResultContext(context.Background(), key, value)
+}
+
+// ResultContext will retrieve a item from the cache with the given
+// context and stores the result in the value pointed to by value.
+func (s *Store) ResultContext(ctx context.Context, key string, value interface{}) error {
+	c, err := s.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := c.Get(key).Bytes()
+	if err == goredis.Nil {
+		return store.ErrNotFound
 	}
 
 	if err != nil {
 		return err
 	}
 
+	return unmarshal(b, value)
+}
+
+// unmarshal decodes a value from redis. Numbers that has been modified
+// by Increment or Decrement are plain integers without a codec header
+// so redis can modify them, they are decoded as int64.
+func unmarshal(b []byte, value interface{}) error {
+	if n, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+		if b, err = store.Marshal(n); err != nil {
+			return err
+		}
+	}
+
 	return store.Unmarshal(b, value)
 }
 
+// number decodes a number from redis, which is either a plain
+// integer or a number that has been stored with a codec.
+func number(b []byte) (int64, error) {
+	if n, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+		return n, nil
+	}
+
+	var v interface{}
+	if err := store.Unmarshal(b, &v); err != nil {
+		return 0, err
+	}
+
+	return store.Int64(v)
+}
+
+// incrBy increments the number with INCRBY. A number that has been
+// stored with Set has a codec header that INCRBY can't modify, so it's
+// decoded and replaced with the new value as a plain integer instead.
+func incrBy(c goredis.UniversalClient, key string, n int64) (int64, error) {
+	for {
+		v, err := c.IncrBy(key, n).Result()
+		if err == nil || !strings.Contains(err.Error(), "not an integer") {
+			return v, err
+		}
+
+		b, err := c.Get(key).Bytes()
+		if err == goredis.Nil {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if v, err = number(b); err != nil {
+			return 0, fmt.Errorf("redis: value of %s is not a number: %v", key, err)
+		}
+
+		ok, err := incr.Run(c, []string{key}, b, v+n).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		if ok == int64(1) {
+			return v + n, nil
+		}
+	}
+}
+
 // Get will retrieve a item from the cache.
 func (s *Store) Get(key string) (interface{}, error) {
-	var i *store.Item
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext will retrieve a item from the cache with the given context.
+func (s *Store) GetContext(ctx context.Context, key string) (interface{}, error) {
+	var v interface{}
 
-	if err := s.Result(key, &i); err != nil {
+	if err := s.ResultContext(ctx, key, &v); err != nil {
 		return nil, err
 	}
-	
-	if i == nil {
-		return nil, errors.New("empty redis result")
+
+	return v, nil
+}
+
+// GetMulti will retrieve multiple items from the cache with one MGET
+// command, or one GET command per key in a pipeline for cluster and
+// ring clients. Items that don't exists are left out of the result.
+func (s *Store) GetMulti(keys []string) (map[string]interface{}, error) {
+	items := make(map[string]interface{}, len(keys))
+
+	if len(keys) == 0 {
+		return items, nil
 	}
 
-	if i.Object != nil {
-		return i.Object, nil
+	values, err := s.mget(keys)
+	if err != nil {
+		return nil, err
 	}
 
-	var i2 interface{}
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
 
-	if err := s.Result(key, &i2); err != nil {
+		var v interface{}
+		if err := unmarshal([]byte(str), &v); err != nil {
+			return nil, err
+		}
+
+		items[keys[i]] = v
+	}
+
+	return items, nil
+}
+
+// mget returns the values of the keys, missing keys has a nil value.
+func (s *Store) mget(keys []string) ([]interface{}, error) {
+	if _, ok := s.client.(*goredis.Client); ok {
+		return s.client.MGet(keys...).Result()
+	}
+
+	cmds := make([]*goredis.StringCmd, len(keys))
+
+	_, err := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(key)
+		}
+
+		return nil
+	})
+
+	if err != nil && err != goredis.Nil {
 		return nil, err
 	}
 
-	return i2, nil
+	values := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		if v, err := cmd.Result(); err == nil {
+			values[i] = v
+		}
+	}
+
+	return values, nil
+}
+
+// Increment will increment a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Increment(key string, n ...int64) (int64, error) {
+	return s.IncrementContext(context.Background(), key, n...)
+}
+
+// IncrementContext will increment a number in the cache with the given context.
+func (s *Store) IncrementContext(ctx context.Context, key string, n ...int64) (int64, error) {
+	c, err := s.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := incrBy(c, key, store.Delta(n...))
+	if err != nil {
+		return 0, err
+	}
+
+	return v, s.publish(c, opRemove, key)
 }
 
-// Remove will remove a item from the cache.
+// Len will retrieve the number of keys in the redis database,
+// summed over all master nodes in a cluster or shards in a ring.
+func (s *Store) Len() (int, error) {
+	var (
+		mu sync.Mutex
+		n  int64
+	)
+
+	err := s.nodes(func(c *goredis.Client) error {
+		v, err := c.DBSize().Result()
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		n += v
+		mu.Unlock()
+
+		return nil
+	})
+
+	return int(n), err
+}
+
+// Number will retrieve a number from the cache.
+func (s *Store) Number(key string) (int64, error) {
+	return s.NumberContext(context.Background(), key)
+}
+
+// NumberContext will retrieve a number from the cache with the given context.
+func (s *Store) NumberContext(ctx context.Context, key string) (int64, error) {
+	c, err := s.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := c.Get(key).Bytes()
+	if err == goredis.Nil {
+		return 0, store.ErrNotFound
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return number(b)
+}
+
+// Persist will remove the expiration of a item in the cache.
+func (s *Store) Persist(key string) error {
+	ok, err := s.client.Persist(key).Result()
+	if err != nil || ok {
+		return err
+	}
+
+	// PERSIST returns false both for missing keys and
+	// keys without a expiration.
+	n, err := s.client.Exists(key).Result()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// Remove will remove a item from the cache, store.ErrNotFound
+// is returned if the item don't exists.
 func (s *Store) Remove(key string) error {
-	return s.client.Del(key).Err()
+	return s.RemoveContext(context.Background(), key)
+}
+
+// RemoveContext will remove a item from the cache with the given context.
+func (s *Store) RemoveContext(ctx context.Context, key string) error {
+	c, err := s.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.Del(key).Result()
+	if err != nil {
+		return err
+	}
+
+	// Other instances may still have the item in their local
+	// stores, so the removal is published even if it's missing.
+	if err := s.publish(c, opRemove, key); err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// RemoveMulti will remove multiple items from the cache with
+// one DEL command, keys that don't exists are ignored.
+func (s *Store) RemoveMulti(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := s.del(keys); err != nil {
+		return err
+	}
+
+	return s.publish(s.client, opRemove, keys...)
+}
+
+// Scan will call fn for each key in the cache that starts with the
+// prefix. SCAN is used so redis is never blocked by a KEYS command,
+// a key may be passed to fn more than once if the database is
+// modified during the scan.
+func (s *Store) Scan(prefix string, fn func(string) bool) error {
+	err := s.scan(prefix, func(keys []string) error {
+		for _, k := range keys {
+			if !fn(k) {
+				return errStop
+			}
+		}
+
+		return nil
+	})
+
+	if err == errStop {
+		return nil
+	}
+
+	return err
 }
 
 // Set will store a item in the cache.
 func (s *Store) Set(key string, value interface{}, expiration time.Duration) error {
-	var b []byte
-	var err error
-
-	switch value.(type) {
-	case []byte:
-		b = value.([]byte)
-	default:
-		b, err = store.Marshal(value)
-		if err != nil {
-			return err
+	return s.SetContext(context.Background(), key, value, expiration)
+}
+
+// SetContext will store a item in the cache with the given context.
+func (s *Store) SetContext(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	c, err := s.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := store.MarshalWith(s.codec, value)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Set(key, b, expiration).Err(); err != nil {
+		return err
+	}
+
+	return s.publish(c, opRemove, key)
+}
+
+// SetMulti will store multiple items in the cache in one pipeline.
+func (s *Store) SetMulti(items map[string]interface{}, expiration time.Duration) error {
+	_, err := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		for key, value := range items {
+			b, err := store.MarshalWith(s.codec, value)
+			if err != nil {
+				return err
+			}
+
+			pipe.Set(key, b, expiration)
 		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	return s.publish(s.client, opRemove, keys...)
+}
+
+// Touch will set a new expiration on a item in the cache.
+func (s *Store) Touch(key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return s.Persist(key)
+	}
+
+	ok, err := s.client.PExpire(key, ttl).Result()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// TTL will retrieve the remaining time to live of a item in the cache.
+func (s *Store) TTL(key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// PTTL returns -2 for missing keys and -1 for keys
+	// without a expiration.
+	switch ttl {
+	case -2 * time.Millisecond:
+		return 0, store.ErrNotFound
+	case -1 * time.Millisecond:
+		return 0, nil
 	}
 
-	return s.client.Set(key, b, expiration).Err()
+	return ttl, nil
 }
@@ -1,9 +1,17 @@
 package redis
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+	"github.com/frozzare/go-cache/store/memory"
 )
 
 func TestStore(t *testing.T) {
@@ -80,3 +88,585 @@ func TestStoreStruct(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestStoreIncrement(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	c.Remove("number")
+
+	if v, err := c.Increment("number"); err != nil || v != 1 {
+		t.Fatalf("Expected 1, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Increment("number", 5); err != nil || v != 6 {
+		t.Fatalf("Expected 6, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number", 2); err != nil || v != 4 {
+		t.Fatalf("Expected 4, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Number("number"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+
+	if err := c.Remove("number"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreIncrementCodec(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	if err := c.Set("number", 5, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Increment("number"); err != nil || v != 6 {
+		t.Fatalf("Expected 6, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Decrement("number", 2); err != nil || v != 4 {
+		t.Fatalf("Expected 4, got %d (%v)", v, err)
+	}
+
+	if v, err := c.Get("number"); err != nil || v != int64(4) {
+		t.Fatalf("Expected 4, got %v (%v)", v, err)
+	}
+
+	if items, err := c.(store.BatchStore).GetMulti([]string{"number"}); err != nil || items["number"] != int64(4) {
+		t.Fatalf("Expected 4, got %v (%v)", items["number"], err)
+	}
+
+	if ttl, err := c.(store.TTLStore).TTL("number"); err != nil || ttl <= 0 {
+		t.Fatalf("Expected the expiration to be kept, got %s (%v)", ttl, err)
+	}
+
+	if err := c.Set("number", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Increment("number"); err == nil {
+		t.Fatal("Expected a error when incrementing a string")
+	}
+
+	if err := c.Remove("number"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalNumber(t *testing.T) {
+	encoded, err := store.Marshal(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"Plain", []byte("5")},
+		{"Codec", encoded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := unmarshal(tt.payload, &v); err != nil || reflect.ValueOf(v).Int() != 5 {
+				t.Fatalf("Expected 5, got %v (%v)", v, err)
+			}
+
+			var i int64
+			if err := unmarshal(tt.payload, &i); err != nil || i != 5 {
+				t.Fatalf("Expected 5, got %d (%v)", i, err)
+			}
+
+			if n, err := number(tt.payload); err != nil || n != 5 {
+				t.Fatalf("Expected 5, got %d (%v)", n, err)
+			}
+		})
+	}
+}
+
+func TestStoreIncrementConcurrent(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	c.Remove("number")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := c.Increment("number"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if v, err := c.Number("number"); err != nil || v != 50 {
+		t.Fatalf("Expected 50, got %d (%v)", v, err)
+	}
+
+	if err := c.Remove("number"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreNotFound(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	if _, err := c.Get("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	var v string
+	if err := c.Result("missing", &v); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if _, err := c.Number("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Remove("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreMulti(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	s := c.(store.BatchStore)
+
+	items := map[string]interface{}{
+		"a": "go",
+		"b": 1,
+		"c": []string{"abc"},
+	}
+
+	if err := s.SetMulti(items, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.GetMulti([]string{"a", "b", "c", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(r, items) {
+		t.Fatalf("%v does not match the expected value: %v", r, items)
+	}
+
+	if err := s.RemoveMulti([]string{"a", "b", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = s.GetMulti([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 || r["c"] == nil {
+		t.Fatalf("Expected only c, got %v", r)
+	}
+
+	c.Remove("c")
+}
+
+func TestStoreContext(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	s := c.(store.ContextStore)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := s.SetContext(ctx, "context", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := s.GetContext(ctx, "context"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	cancel()
+
+	if _, err := s.GetContext(ctx, "context"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if err := c.Remove("context"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreFlushPrefix(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	for _, k := range []string{"a:1", "a:2", "ab", "b:1"} {
+		if err := c.Set(k, k, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.(store.PrefixStore).FlushPrefix("a:"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a:1", "a:2"} {
+		if _, err := c.Get(k); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected store.ErrNotFound for %s, got %v", k, err)
+		}
+	}
+
+	for _, k := range []string{"ab", "b:1"} {
+		if v, err := c.Get(k); err != nil || v != k {
+			t.Fatalf("Expected %s, got %v (%v)", k, v, err)
+		}
+
+		c.Remove(k)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if v := escape(`a*b?[c]\`); v != `a\*b\?\[c\]\\` {
+		t.Fatalf("%s does not match the expected value", v)
+	}
+}
+
+func TestStoreConditional(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	s := c.(store.ConditionalStore)
+
+	c.Remove("cond")
+
+	if ok, err := s.Replace("cond", "a", 0); err != nil || ok {
+		t.Fatalf("Expected replace of missing key to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Add("cond", "a", 0); err != nil || !ok {
+		t.Fatalf("Expected add to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Add("cond", "b", 0); err != nil || ok {
+		t.Fatalf("Expected add of existing key to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.Replace("cond", "b", 0); err != nil || !ok {
+		t.Fatalf("Expected replace to succeed, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.CompareAndSwap("cond", "a", "c", 0); err != nil || ok {
+		t.Fatalf("Expected compare and swap with wrong value to fail, got %v (%v)", ok, err)
+	}
+
+	if ok, err := s.CompareAndSwap("cond", "b", "c", time.Hour); err != nil || !ok {
+		t.Fatalf("Expected compare and swap to succeed, got %v (%v)", ok, err)
+	}
+
+	if v, err := c.Get("cond"); err != nil || v != "c" {
+		t.Fatalf("Expected c, got %v (%v)", v, err)
+	}
+
+	if err := c.Remove("cond"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreTouch(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	s := c.(store.TTLStore)
+
+	if _, err := s.TTL("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := s.Touch("missing", time.Hour); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := s.Persist("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Set("touch", "go", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("Expected ttl of at most a minute, got %s (%v)", ttl, err)
+	}
+
+	if err := s.Touch("touch", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl <= time.Minute || ttl > time.Hour {
+		t.Fatalf("Expected ttl of at most a hour, got %s (%v)", ttl, err)
+	}
+
+	if err := s.Persist("touch"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := s.TTL("touch"); err != nil || ttl != 0 {
+		t.Fatalf("Expected no ttl, got %s (%v)", ttl, err)
+	}
+
+	if v, err := c.Get("touch"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	if err := c.Remove("touch"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreScan(t *testing.T) {
+	c := NewStore(nil)
+
+	defer c.Close()
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2500; i++ {
+		if err := c.Set(fmt.Sprintf("scan:%04d", i), i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Set("other", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	s := c.(store.ScanStore)
+
+	keys := make(map[string]bool)
+	err := s.Scan("scan:", func(key string) bool {
+		keys[key] = true
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2500 || keys["other"] {
+		t.Fatalf("Expected 2500 keys, got %d", len(keys))
+	}
+
+	n := 0
+	err = s.Scan("", func(key string) bool {
+		n++
+		return n < 10
+	})
+
+	if err != nil || n != 10 {
+		t.Fatalf("Expected scan to stop after 10 keys, got %d (%v)", n, err)
+	}
+
+	if n, err := s.Len(); err != nil || n != 2501 {
+		t.Fatalf("Expected 2501, got %d (%v)", n, err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	id, op, key, ok := parseMessage(message("a", opRemove, "user|1"))
+	if !ok || id != "a" || op != opRemove || key != "user|1" {
+		t.Fatalf("Unexpected message: %s %s %s %v", id, op, key, ok)
+	}
+
+	if _, _, _, ok := parseMessage("a|del"); ok {
+		t.Fatal("Expected invalid message")
+	}
+}
+
+func TestSubscriptionHandle(t *testing.T) {
+	local := memory.NewStore()
+	sub := &Subscription{id: "self", local: local}
+
+	for _, k := range []string{"a:1", "a:2", "b:1"} {
+		local.Set(k, k, 0)
+	}
+
+	sub.handle(message("self", opRemove, "a:1"))
+
+	if _, err := local.Get("a:1"); err != nil {
+		t.Fatalf("Expected own messages to be ignored, got %v", err)
+	}
+
+	sub.handle(message("other", opRemove, "a:1"))
+
+	if _, err := local.Get("a:1"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	sub.handle(message("other", opPrefix, "a:"))
+
+	if _, err := local.Get("a:2"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if v, err := local.Get("b:1"); err != nil || v != "b:1" {
+		t.Fatalf("Expected b:1, got %v (%v)", v, err)
+	}
+
+	sub.handle(message("other", opFlush, ""))
+
+	if _, err := local.Get("b:1"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreSubscribe(t *testing.T) {
+	a := NewStore(nil, WithInvalidation("invalidation"))
+	b := NewStore(nil, WithInvalidation("invalidation"))
+
+	defer a.Close()
+	defer b.Close()
+
+	if _, err := NewStore(nil).(*Store).Subscribe(memory.NewStore()); err != ErrNoChannel {
+		t.Fatalf("Expected ErrNoChannel, got %v", err)
+	}
+
+	local := memory.NewStore()
+
+	sub, err := b.(*Store).Subscribe(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sub.Close()
+
+	if err := local.Set("name", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Set("name", "redis", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, err := local.Get("name"); errors.Is(err, store.ErrNotFound) {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := local.Get("name"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := a.Remove("name"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRingStore(t *testing.T) {
+	c := NewRingStore(&RingOptions{
+		Addrs: map[string]string{"a": "localhost:6379"},
+	})
+
+	defer c.Close()
+
+	items := map[string]interface{}{"ring:1": "a", "ring:2": "b", "other": "c"}
+
+	if err := c.(store.BatchStore).SetMulti(items, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := c.(store.BatchStore).GetMulti([]string{"ring:1", "ring:2", "missing"})
+	if err != nil || len(v) != 2 || v["ring:1"] != "a" {
+		t.Fatalf("Expected two items, got %v (%v)", v, err)
+	}
+
+	if n, err := c.(store.ScanStore).Len(); err != nil || n < 3 {
+		t.Fatalf("Expected at least 3 keys, got %d (%v)", n, err)
+	}
+
+	if err := c.(store.PrefixStore).FlushPrefix("ring:"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("ring:1"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("other"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestRingStoreScanStop(t *testing.T) {
+	// Both shards uses the same server, so
+	// each key is found by both of them.
+	c := NewRingStore(&RingOptions{
+		Addrs: map[string]string{"a": "localhost:6379", "b": "localhost:6379"},
+	})
+
+	defer c.Close()
+
+	if err := c.Set("scan:1", "a", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Remove("scan:1")
+
+	var calls int32
+
+	err := c.(store.ScanStore).Scan("scan:", func(string) bool {
+		atomic.AddInt32(&calls, 1)
+		return false
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("Expected one call, got %d", n)
+	}
+}
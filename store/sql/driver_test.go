@@ -0,0 +1,295 @@
+package sql
+
+import (
+	sqldb "database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is a database driver that keeps the cache table in memory.
+// It only understands the queries that are used by the store, which are
+// recognized by their keywords so the same driver works for all dialects.
+type fakeDriver struct{}
+
+var fakeDBs = struct {
+	sync.Mutex
+	dbs map[string]*fakeDB
+}{dbs: make(map[string]*fakeDB)}
+
+func init() {
+	sqldb.Register("fake", fakeDriver{})
+}
+
+// fakeRow represents a row in the cache table.
+type fakeRow struct {
+	value []byte
+	exp   int64
+}
+
+// fakeDB represents a database, mu is held by a transaction
+// until it's done or by a statement outside a transaction.
+type fakeDB struct {
+	mu      sync.Mutex
+	rows    map[string]fakeRow
+	tables  []string
+	queries []string
+}
+
+// fakeOpen returns the database with the given name and a
+// connection pool to it.
+func fakeOpen(name string) (*fakeDB, *sqldb.DB) {
+	db, _ := sqldb.Open("fake", name)
+	return fakeDBFor(name), db
+}
+
+func fakeDBFor(name string) *fakeDB {
+	fakeDBs.Lock()
+	defer fakeDBs.Unlock()
+
+	db, ok := fakeDBs.dbs[name]
+	if !ok {
+		db = &fakeDB{rows: make(map[string]fakeRow)}
+		fakeDBs.dbs[name] = db
+	}
+
+	return db
+}
+
+// executed returns the queries that has been executed.
+func (db *fakeDB) executed() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return append([]string(nil), db.queries...)
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{db: fakeDBFor(name)}, nil
+}
+
+type fakeConn struct {
+	db     *fakeDB
+	tx     bool
+	backup map[string]fakeRow
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.tx = true
+	c.backup = make(map[string]fakeRow, len(c.db.rows))
+	for k, r := range c.db.rows {
+		c.backup[k] = r
+	}
+
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.tx = false
+	c.db.mu.Unlock()
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.rows = c.backup
+	c.tx = false
+	c.db.mu.Unlock()
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+// lock locks the database unless the statement is in a transaction.
+func (s *fakeStmt) lock() func() {
+	db := s.conn.db
+
+	if s.conn.tx {
+		db.queries = append(db.queries, s.query)
+		return func() {}
+	}
+
+	db.mu.Lock()
+	db.queries = append(db.queries, s.query)
+	return db.mu.Unlock
+}
+
+// unlike returns the prefix in a LIKE pattern from the store.
+func unlike(pattern string) string {
+	pattern = strings.TrimSuffix(pattern, "%")
+
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '!' && i+1 < len(pattern) {
+			i++
+		}
+
+		b.WriteByte(pattern[i])
+	}
+
+	return b.String()
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	defer s.lock()()
+
+	db := s.conn.db
+	q := s.query
+
+	switch {
+	case strings.HasPrefix(q, "CREATE TABLE"):
+		db.tables = append(db.tables, strings.Fields(q)[5])
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(q, "CREATE INDEX"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(q, "INSERT"):
+		key := args[0].(string)
+
+		if strings.Contains(q, "DO NOTHING") || strings.HasPrefix(q, "INSERT IGNORE") {
+			if _, ok := db.rows[key]; ok {
+				return driver.RowsAffected(0), nil
+			}
+
+			db.rows[key] = fakeRow{value: args[1].([]byte)}
+			return driver.RowsAffected(1), nil
+		}
+
+		db.rows[key] = fakeRow{value: args[1].([]byte), exp: args[2].(int64)}
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(q, "UPDATE"):
+		key := args[2].(string)
+		if _, ok := db.rows[key]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+
+		db.rows[key] = fakeRow{value: args[0].([]byte), exp: args[1].(int64)}
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(q, "DELETE"):
+		var match func(string, fakeRow) bool
+
+		switch {
+		case strings.Contains(q, "cache_key = "):
+			match = func(k string, _ fakeRow) bool { return k == args[0].(string) }
+		case strings.Contains(q, "LIKE"):
+			prefix := unlike(args[0].(string))
+			match = func(k string, _ fakeRow) bool { return strings.HasPrefix(k, prefix) }
+		case strings.Contains(q, "expires_at <="):
+			match = func(_ string, r fakeRow) bool { return r.exp > 0 && r.exp <= args[0].(int64) }
+		default:
+			match = func(string, fakeRow) bool { return true }
+		}
+
+		var n int64
+		for k, r := range db.rows {
+			if match(k, r) {
+				delete(db.rows, k)
+				n++
+			}
+		}
+
+		return driver.RowsAffected(n), nil
+	}
+
+	return nil, fmt.Errorf("fake: unknown exec: %s", q)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	defer s.lock()()
+
+	db := s.conn.db
+	q := s.query
+
+	switch {
+	case strings.HasPrefix(q, "SELECT value, expires_at"):
+		r, ok := db.rows[args[0].(string)]
+		if !ok {
+			return &fakeRows{columns: []string{"value", "expires_at"}}, nil
+		}
+
+		return &fakeRows{
+			columns: []string{"value", "expires_at"},
+			data:    [][]driver.Value{{append([]byte(nil), r.value...), r.exp}},
+		}, nil
+	case strings.HasPrefix(q, "SELECT COUNT(*)"):
+		var n int64
+		for _, r := range db.rows {
+			if r.exp == 0 || r.exp > args[0].(int64) {
+				n++
+			}
+		}
+
+		return &fakeRows{columns: []string{"count"}, data: [][]driver.Value{{n}}}, nil
+	case strings.HasPrefix(q, "SELECT cache_key"):
+		prefix := unlike(args[0].(string))
+		last := args[1].(string)
+		now := args[2].(int64)
+
+		var keys []string
+		for k, r := range db.rows {
+			if strings.HasPrefix(k, prefix) && k > last && (r.exp == 0 || r.exp > now) {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		if len(keys) > scanBatch {
+			keys = keys[:scanBatch]
+		}
+
+		rows := &fakeRows{columns: []string{"cache_key"}}
+		for _, k := range keys {
+			rows.data = append(rows.data, []driver.Value{k})
+		}
+
+		return rows, nil
+	}
+
+	return nil, fmt.Errorf("fake: unknown query: %s", q)
+}
+
+type fakeRows struct {
+	columns []string
+	data    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.data[0])
+	r.data = r.data[1:]
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// Option configures a database store.
+type Option func(*Store)
+
+// WithCleanupInterval will start a goroutine that removes expired
+// items from the table at the given interval. The goroutine is
+// stopped when the store is closed.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.interval = interval
+	}
+}
+
+// WithCodec sets the codec that is used to encode values.
+func WithCodec(c store.Codec) Option {
+	return func(s *Store) {
+		s.codec = c
+	}
+}
+
+// WithDialect sets the SQL dialect of the database, it's only
+// needed if it can't be found from the database driver.
+func WithDialect(d Dialect) Option {
+	return func(s *Store) {
+		s.dialect = d
+	}
+}
+
+// WithTable sets the name of the table, the default is cache.
+// The name is used as it is in the queries.
+func WithTable(name string) Option {
+	return func(s *Store) {
+		s.table = name
+	}
+}
@@ -0,0 +1,444 @@
+package sql
+
+import (
+	sqldb "database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// scanBatch is the number of keys that are read in one query by Scan.
+const scanBatch = 1000
+
+// Dialect represents the SQL dialect of a database.
+type Dialect int
+
+// Dialects that are supported by the store.
+const (
+	SQLite Dialect = iota + 1
+	Postgres
+	MySQL
+)
+
+// ErrUnknownDialect is returned by NewStore if the dialect can't be
+// found from the database driver and no dialect is given.
+var ErrUnknownDialect = errors.New("sql: unknown dialect")
+
+// queries represents the queries that are used by the store.
+type queries struct {
+	create  []string
+	get     string
+	lock    string
+	upsert  string
+	insert  string
+	update  string
+	remove  string
+	flush   string
+	prefix  string
+	scan    string
+	count   string
+	expired string
+}
+
+// Store represents the database cache store.
+type Store struct {
+	db       *sqldb.DB
+	dialect  Dialect
+	table    string
+	codec    store.Codec
+	interval time.Duration
+	q        queries
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewStore will create a new database store with the given options, the
+// table is created if it don't exists. The dialect is found from the
+// database driver unless it's given with WithDialect.
+func NewStore(db *sqldb.DB, opts ...Option) (store.Store, error) {
+	s := &Store{
+		db:    db,
+		table: "cache",
+		stop:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.dialect == 0 {
+		s.dialect = dialectOf(db)
+	}
+
+	if s.dialect == 0 {
+		return nil, ErrUnknownDialect
+	}
+
+	s.q = s.queries()
+
+	for _, q := range s.q.create {
+		if _, err := db.Exec(q); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.interval > 0 {
+		go s.janitor()
+	}
+
+	return s, nil
+}
+
+// dialectOf returns the dialect for the driver of the database
+// from the import path of its package, since the type names don't
+// tell, e.g. pgx's driver is stdlib.Driver.
+func dialectOf(db *sqldb.DB) Dialect {
+	t := reflect.TypeOf(db.Driver())
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return dialectFor(t.PkgPath())
+}
+
+// dialectFor returns the dialect for the driver package with the
+// given import path, zero is returned if it's unknown.
+func dialectFor(pkg string) Dialect {
+	pkg = strings.ToLower(pkg)
+
+	switch {
+	case strings.Contains(pkg, "sqlite"):
+		return SQLite
+	case strings.HasSuffix(pkg, "/pq"), strings.Contains(pkg, "pgx"), strings.Contains(pkg, "postgres"):
+		return Postgres
+	case strings.Contains(pkg, "mysql"):
+		return MySQL
+	}
+
+	return 0
+}
+
+// queries returns the queries for the dialect and table of the store.
+func (s *Store) queries() queries {
+	t := s.table
+
+	q := queries{
+		get:     "SELECT value, expires_at FROM " + t + " WHERE cache_key = ?",
+		upsert:  "INSERT INTO " + t + " (cache_key, value, expires_at) VALUES (?, ?, ?) ON CONFLICT (cache_key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at",
+		insert:  "INSERT INTO " + t + " (cache_key, value, expires_at) VALUES (?, ?, 0) ON CONFLICT (cache_key) DO NOTHING",
+		update:  "UPDATE " + t + " SET value = ?, expires_at = ? WHERE cache_key = ?",
+		remove:  "DELETE FROM " + t + " WHERE cache_key = ?",
+		flush:   "DELETE FROM " + t,
+		prefix:  "DELETE FROM " + t + " WHERE cache_key LIKE ? ESCAPE '!'",
+		scan:    "SELECT cache_key FROM " + t + " WHERE cache_key LIKE ? ESCAPE '!' AND cache_key > ? AND (expires_at = 0 OR expires_at > ?) ORDER BY cache_key LIMIT " + strconv.Itoa(scanBatch),
+		count:   "SELECT COUNT(*) FROM " + t + " WHERE expires_at = 0 OR expires_at > ?",
+		expired: "DELETE FROM " + t + " WHERE expires_at > 0 AND expires_at <= ?",
+	}
+
+	q.lock = q.get + " FOR UPDATE"
+
+	switch s.dialect {
+	case SQLite:
+		// SQLite locks the whole database when the transaction
+		// writes, so the row don't have to be locked.
+		q.lock = q.get
+		q.create = []string{
+			"CREATE TABLE IF NOT EXISTS " + t + " (cache_key VARCHAR(255) NOT NULL PRIMARY KEY, value BLOB NOT NULL, expires_at BIGINT NOT NULL DEFAULT 0)",
+			"CREATE INDEX IF NOT EXISTS " + t + "_expires_at ON " + t + " (expires_at)",
+		}
+	case Postgres:
+		q.create = []string{
+			"CREATE TABLE IF NOT EXISTS " + t + " (cache_key VARCHAR(255) NOT NULL PRIMARY KEY, value BYTEA NOT NULL, expires_at BIGINT NOT NULL DEFAULT 0)",
+			"CREATE INDEX IF NOT EXISTS " + t + "_expires_at ON " + t + " (expires_at)",
+		}
+	case MySQL:
+		// The key is binary since the default collation is case
+		// insensitive, which would make keys that only differs in
+		// case share a row and match the same LIKE patterns.
+		q.create = []string{
+			"CREATE TABLE IF NOT EXISTS " + t + " (cache_key VARBINARY(255) NOT NULL PRIMARY KEY, value LONGBLOB NOT NULL, expires_at BIGINT NOT NULL DEFAULT 0, INDEX " + t + "_expires_at (expires_at))",
+		}
+		q.upsert = "INSERT INTO " + t + " (cache_key, value, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value), expires_at = VALUES(expires_at)"
+		q.insert = "INSERT IGNORE INTO " + t + " (cache_key, value, expires_at) VALUES (?, ?, 0)"
+	}
+
+	if s.dialect == Postgres {
+		for _, p := range []*string{&q.get, &q.lock, &q.upsert, &q.insert, &q.update, &q.remove, &q.prefix, &q.scan, &q.count, &q.expired} {
+			*p = rebind(*p)
+		}
+	}
+
+	return q
+}
+
+// rebind replaces the question mark placeholders in
+// the query with numbered placeholders for Postgres.
+func rebind(q string) string {
+	var b strings.Builder
+
+	n := 0
+	for _, r := range q {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// like returns a LIKE pattern that matches keys that starts
+// with the prefix, with ! as the escape character.
+func like(prefix string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return r.Replace(prefix) + "%"
+}
+
+// janitor removes expired items at the configured interval
+// until the store is closed.
+func (s *Store) janitor() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.DeleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// DeleteExpired will remove all expired items from the table.
+func (s *Store) DeleteExpired() error {
+	_, err := s.db.Exec(s.q.expired, store.Now().UnixNano())
+	return err
+}
+
+// Close will stop the cleanup goroutine, the database
+// is not closed since it's owned by the caller.
+func (s *Store) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+
+	return nil
+}
+
+// Decrement will decrement a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Decrement(key string, n ...int64) (int64, error) {
+	return s.increment(key, -store.Delta(n...))
+}
+
+// Flush remove all items from the cache.
+func (s *Store) Flush() error {
+	_, err := s.db.Exec(s.q.flush)
+	return err
+}
+
+// FlushPrefix will remove all items with keys that starts with the prefix.
+func (s *Store) FlushPrefix(prefix string) error {
+	_, err := s.db.Exec(s.q.prefix, like(prefix))
+	return err
+}
+
+// Get will retrieve a item from the cache.
+func (s *Store) Get(key string) (interface{}, error) {
+	var v interface{}
+
+	if err := s.Result(key, &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Increment will increment a number in the cache by one or by
+// the given value and returns the new value.
+func (s *Store) Increment(key string, n ...int64) (int64, error) {
+	return s.increment(key, store.Delta(n...))
+}
+
+// increment modifies the number in a transaction. A row with zero is
+// inserted first if the key don't exists, so the row can be locked
+// by all transactions that modifies the same number. The expiration
+// of the item is kept.
+func (s *Store) increment(key string, n int64) (int64, error) {
+	zero, err := store.MarshalWith(s.codec, int64(0))
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.q.insert, key, zero); err != nil {
+		return 0, err
+	}
+
+	var (
+		buf []byte
+		exp int64
+		v   int64
+	)
+
+	if err := tx.QueryRow(s.q.lock, key).Scan(&buf, &exp); err != nil {
+		return 0, err
+	}
+
+	if store.Expired(exp) {
+		exp = 0
+	} else if err := store.Unmarshal(buf, &v); err != nil {
+		return 0, err
+	}
+
+	v += n
+
+	if buf, err = store.MarshalWith(s.codec, v); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(s.q.update, buf, exp, key); err != nil {
+		return 0, err
+	}
+
+	return v, tx.Commit()
+}
+
+// Len will retrieve the number of items in the cache that has not expired.
+func (s *Store) Len() (int, error) {
+	var n int
+
+	err := s.db.QueryRow(s.q.count, store.Now().UnixNano()).Scan(&n)
+
+	return n, err
+}
+
+// Number will retrieve a number from the cache.
+func (s *Store) Number(key string) (int64, error) {
+	var v int64
+
+	if err := s.Result(key, &v); err != nil {
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// Remove will remove a item from the cache, store.ErrNotFound
+// is returned if the item don't exists.
+func (s *Store) Remove(key string) error {
+	res, err := s.db.Exec(s.q.remove, key)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// Result will retrieve a item from the cache and stores the
+// result in the value pointed to by value.
+func (s *Store) Result(key string, value interface{}) error {
+	var (
+		buf []byte
+		exp int64
+	)
+
+	err := s.db.QueryRow(s.q.get, key).Scan(&buf, &exp)
+	if err == sqldb.ErrNoRows {
+		return store.ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if store.Expired(exp) {
+		return store.ErrExpired
+	}
+
+	return store.Unmarshal(buf, value)
+}
+
+// Scan will call fn for each key in the cache that starts with the
+// prefix. The keys are read in batches and fn is called when no
+// rows are open, so fn can modify the store.
+func (s *Store) Scan(prefix string, fn func(string) bool) error {
+	last := ""
+
+	for {
+		keys, err := s.scan(prefix, last)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if !fn(k) {
+				return nil
+			}
+		}
+
+		if len(keys) < scanBatch {
+			return nil
+		}
+
+		last = keys[len(keys)-1]
+	}
+}
+
+// scan returns the next batch of keys after the last key.
+func (s *Store) scan(prefix, last string) ([]string, error) {
+	rows, err := s.db.Query(s.q.scan, like(prefix), last, store.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keys []string
+
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// Set will store a item in the cache.
+func (s *Store) Set(key string, value interface{}, expiration time.Duration) error {
+	buf, err := store.MarshalWith(s.codec, value)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(s.q.upsert, key, buf, store.ExpiresAt(expiration))
+	return err
+}
@@ -0,0 +1,301 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+var dialects = map[string]Dialect{
+	"SQLite":   SQLite,
+	"Postgres": Postgres,
+	"MySQL":    MySQL,
+}
+
+// forEachDialect runs fn with a new store for each dialect.
+func forEachDialect(t *testing.T, fn func(*testing.T, store.Store)) {
+	for name, d := range dialects {
+		t.Run(name, func(t *testing.T) {
+			_, db := fakeOpen(t.Name())
+
+			c, err := NewStore(db, WithDialect(d))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer c.Close()
+
+			fn(t, c)
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, c store.Store) {
+		values := []interface{}{
+			"go",
+			true,
+			[]string{"abc"},
+			1,
+			1.2,
+			[]int{1, 2, 3},
+			uint64(3),
+			map[string]interface{}{"name": "go"},
+		}
+
+		for _, v := range values {
+			if err := c.Set("value", v, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			if r, err := c.Get("value"); err != nil || !reflect.DeepEqual(r, v) {
+				t.Fatalf("%v does not match the expected value: %v (%v)", r, v, err)
+			}
+		}
+
+		if err := c.Remove("value"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Remove("value"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected store.ErrNotFound, got %v", err)
+		}
+
+		if _, err := c.Get("value"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected store.ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreExpired(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	forEachDialect(t, func(t *testing.T, c store.Store) {
+		if err := c.Set("ttl", "go", time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Set("forever", "go", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(time.Second)
+
+		if _, err := c.Get("ttl"); !errors.Is(err, store.ErrExpired) {
+			t.Fatalf("Expected store.ErrExpired, got %v", err)
+		}
+
+		if n, err := c.(store.ScanStore).Len(); err != nil || n != 1 {
+			t.Fatalf("Expected 1 item, got %d (%v)", n, err)
+		}
+
+		if err := c.(*Store).DeleteExpired(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Remove("ttl"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected expired item to be deleted, got %v", err)
+		}
+
+		if v, err := c.Get("forever"); err != nil || v != "go" {
+			t.Fatalf("Expected go, got %v (%v)", v, err)
+		}
+	})
+}
+
+func TestStoreIncrement(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, c store.Store) {
+		if v, err := c.Increment("number"); err != nil || v != 1 {
+			t.Fatalf("Expected 1, got %d (%v)", v, err)
+		}
+
+		if v, err := c.Increment("number", 5); err != nil || v != 6 {
+			t.Fatalf("Expected 6, got %d (%v)", v, err)
+		}
+
+		if v, err := c.Decrement("number", 2); err != nil || v != 4 {
+			t.Fatalf("Expected 4, got %d (%v)", v, err)
+		}
+
+		if v, err := c.Number("number"); err != nil || v != 4 {
+			t.Fatalf("Expected 4, got %d (%v)", v, err)
+		}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if _, err := c.Increment("concurrent"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if v, err := c.Number("concurrent"); err != nil || v != 50 {
+			t.Fatalf("Expected 50, got %d (%v)", v, err)
+		}
+	})
+}
+
+func TestStoreFlushPrefix(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, c store.Store) {
+		for _, k := range []string{"a:1", "a:2", "a_b", "a%", "ab", "b:1"} {
+			if err := c.Set(k, k, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := c.(store.PrefixStore).FlushPrefix("a:"); err != nil {
+			t.Fatal(err)
+		}
+
+		// _ and % are escaped so they only matches themselves.
+		if err := c.(store.PrefixStore).FlushPrefix("a_"); err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		err := c.(store.ScanStore).Scan("", func(key string) bool {
+			keys = append(keys, key)
+			return true
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(keys, []string{"a%", "ab", "b:1"}) {
+			t.Fatalf("Expected [a%% ab b:1], got %v", keys)
+		}
+
+		if err := c.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := c.(store.ScanStore).Len(); err != nil || n != 0 {
+			t.Fatalf("Expected 0 items, got %d (%v)", n, err)
+		}
+	})
+}
+
+func TestStoreScan(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, c store.Store) {
+		for i := 0; i < 2500; i++ {
+			if err := c.Set(fmt.Sprintf("scan:%04d", i), i, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		n := 0
+		err := c.(store.ScanStore).Scan("scan:", func(key string) bool {
+			if key != fmt.Sprintf("scan:%04d", n) {
+				t.Fatalf("Expected scan:%04d, got %s", n, key)
+			}
+
+			n++
+			return true
+		})
+
+		if err != nil || n != 2500 {
+			t.Fatalf("Expected 2500 keys, got %d (%v)", n, err)
+		}
+	})
+}
+
+func TestStoreQueries(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		key     string
+		table   string
+		upsert  string
+		get     string
+	}{
+		{SQLite, "cache_key VARCHAR(255)", "BLOB", "ON CONFLICT (cache_key) DO UPDATE", "WHERE cache_key = ?"},
+		{Postgres, "cache_key VARCHAR(255)", "BYTEA", "ON CONFLICT (cache_key) DO UPDATE", "WHERE cache_key = $1"},
+		{MySQL, "cache_key VARBINARY(255)", "LONGBLOB", "ON DUPLICATE KEY UPDATE", "WHERE cache_key = ?"},
+	}
+
+	for _, tt := range tests {
+		fake, db := fakeOpen(t.Name() + fmt.Sprint(tt.dialect))
+
+		c, err := NewStore(db, WithDialect(tt.dialect), WithTable("items"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Set("a", "go", 0)
+		c.Get("a")
+
+		q := fake.executed()
+
+		if len(fake.tables) != 1 || fake.tables[0] != "items" {
+			t.Fatalf("Expected table items, got %v", fake.tables)
+		}
+
+		if !strings.Contains(q[0], tt.key) {
+			t.Fatalf("Expected %s column, got %s", tt.key, q[0])
+		}
+
+		if !strings.Contains(q[0], tt.table) {
+			t.Fatalf("Expected %s column, got %s", tt.table, q[0])
+		}
+
+		if !strings.Contains(q[len(q)-2], tt.upsert) {
+			t.Fatalf("Expected upsert with %s, got %s", tt.upsert, q[len(q)-2])
+		}
+
+		if !strings.HasSuffix(q[len(q)-1], tt.get) {
+			t.Fatalf("Expected query with %s, got %s", tt.get, q[len(q)-1])
+		}
+	}
+}
+
+func TestStoreUnknownDialect(t *testing.T) {
+	_, db := fakeOpen(t.Name())
+
+	if _, err := NewStore(db); err != ErrUnknownDialect {
+		t.Fatalf("Expected ErrUnknownDialect, got %v", err)
+	}
+}
+
+func TestDialectFor(t *testing.T) {
+	tests := map[string]Dialect{
+		"github.com/mattn/go-sqlite3":    SQLite,
+		"modernc.org/sqlite":             SQLite,
+		"github.com/lib/pq":              Postgres,
+		"github.com/jackc/pgx/v5/stdlib": Postgres,
+		"github.com/go-sql-driver/mysql": MySQL,
+		"github.com/example/driver":      0,
+	}
+
+	for pkg, d := range tests {
+		if v := dialectFor(pkg); v != d {
+			t.Errorf("Expected dialect %d for %s, got %d", d, pkg, v)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	if q := rebind("a = ? AND b > ?"); q != "a = $1 AND b > $2" {
+		t.Fatalf("Unexpected query %s", q)
+	}
+}
+
+func TestLike(t *testing.T) {
+	if p := like("a_b%c!"); p != "a!_b!%c!!%" {
+		t.Fatalf("Unexpected pattern %s", p)
+	}
+}
@@ -1,36 +1,154 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
+var (
+	// ErrNotFound is returned when a item don't exists in the cache.
+	ErrNotFound = errors.New("item not found")
+
+	// ErrExpired is returned when a item exists in the cache but has
+	// expired, it wraps ErrNotFound so it can be checked with errors.Is.
+	ErrExpired = fmt.Errorf("item expired: %w", ErrNotFound)
+
+	// ErrNotSupported is returned when a operation is not
+	// supported by the store.
+	ErrNotSupported = errors.New("not supported by store")
+)
+
 // Store provides a interface to implement cache stores.
 type Store interface {
 	Flush() error
-	//	Decrement(string, ...int64) (int64, error)
+	Decrement(string, ...int64) (int64, error)
 	Get(string) (interface{}, error)
-	//	Number(string) (int64, error)
-	//	Increment(string, ...int64) (int64, error)
-	//	Remember(string, time.Duration, RememberFunc) (interface{}, error)
+	Number(string) (int64, error)
+	Increment(string, ...int64) (int64, error)
 	Remove(string) error
 	Result(string, interface{}) error
 	Set(string, interface{}, time.Duration) error
 	Close() error
 }
 
-// RememberFunc is the function that is used for remember method.
-type RememberFunc func() interface{}
+// BatchStore provides a interface for stores that can retrieve,
+// store and remove multiple items at once. Items that don't exists
+// are left out of the result of GetMulti and ignored by RemoveMulti.
+type BatchStore interface {
+	GetMulti([]string) (map[string]interface{}, error)
+	RemoveMulti([]string) error
+	SetMulti(map[string]interface{}, time.Duration) error
+}
+
+// ConditionalStore provides a interface for stores that can atomically
+// store a item depending on the current item in the store. The returned
+// bool is true if the item was stored.
+type ConditionalStore interface {
+	// Add stores the item only if the key don't exists.
+	Add(string, interface{}, time.Duration) (bool, error)
+	// CompareAndSwap stores the new value only if the current
+	// value of the key is equal to the old value.
+	CompareAndSwap(string, interface{}, interface{}, time.Duration) (bool, error)
+	// Replace stores the item only if the key exists.
+	Replace(string, interface{}, time.Duration) (bool, error)
+}
+
+// ContextStore provides a interface for stores that can take a
+// context. The stores returns the context error if the context is
+// done before the call is made, a call that has been started is not
+// cancelled. The cache checks the context itself before it calls
+// other stores.
+type ContextStore interface {
+	DecrementContext(context.Context, string, ...int64) (int64, error)
+	FlushContext(context.Context) error
+	GetContext(context.Context, string) (interface{}, error)
+	IncrementContext(context.Context, string, ...int64) (int64, error)
+	NumberContext(context.Context, string) (int64, error)
+	RemoveContext(context.Context, string) error
+	ResultContext(context.Context, string, interface{}) error
+	SetContext(context.Context, string, interface{}, time.Duration) error
+}
+
+// PrefixStore provides a interface for stores that can
+// remove all items with keys that starts with a prefix.
+type PrefixStore interface {
+	FlushPrefix(string) error
+}
+
+// ScanStore provides a interface for stores that can enumerate keys,
+// expired items are skipped.
+type ScanStore interface {
+	// Len returns the number of items in the store.
+	Len() (int, error)
+	// Scan calls fn for each key that starts with the prefix,
+	// the scan is stopped when fn returns false.
+	Scan(string, func(string) bool) error
+}
+
+// TTLStore provides a interface for stores that can inspect and
+// change the expiration of existing items.
+type TTLStore interface {
+	// Persist removes the expiration of the item.
+	Persist(string) error
+	// Touch sets a new expiration on the item, a ttl of
+	// zero or less removes the expiration.
+	Touch(string, time.Duration) error
+	// TTL returns the remaining time to live of the item,
+	// zero is returned if the item never expires.
+	TTL(string) (time.Duration, error)
+}
+
+// RememberFunc is the function that is used for remember method,
+// the returned value is stored in the cache unless a error is returned.
+type RememberFunc func() (interface{}, error)
+
+// Now returns the current time, it's used for all expiration
+// checks and can be replaced to control the time in tests.
+var Now = time.Now
+
+// ExpiresAt returns the absolute expiration time in unix nanoseconds
+// for the given ttl, zero is returned if the ttl is zero or less
+// which means that the item never expires.
+func ExpiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	return Now().Add(ttl).UnixNano()
+}
+
+// TTL returns the remaining time until the given absolute expiration
+// time in unix nanoseconds, zero is returned if it never expires.
+func TTL(expiration int64) time.Duration {
+	if expiration <= 0 {
+		return 0
+	}
+
+	ttl := time.Duration(expiration - Now().UnixNano())
+	if ttl <= 0 {
+		return 0
+	}
+
+	return ttl
+}
+
+// Expired returns true if the given absolute expiration time
+// in unix nanoseconds has passed.
+func Expired(expiration int64) bool {
+	return expiration > 0 && Now().UnixNano() >= expiration
+}
 
 // Item represents a item in the cache.
 type Item struct {
-	Expiration time.Duration
+	// Expiration is the absolute expiration time in unix
+	// nanoseconds, zero means that the item never expires.
+	Expiration int64
 	Object     interface{}
 }
 
 // Expired returns true if the item has expired.
 func (item Item) Expired() bool {
-	if item.Expiration == 0 {
-		return false
-	}
-	return time.Now().UnixNano() > int64(item.Expiration)
+	return Expired(item.Expiration)
 }
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiresAt(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	Now = func() time.Time { return now }
+	defer func() { Now = time.Now }()
+
+	tests := []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Nanosecond, now.UnixNano() + 1},
+		{250 * time.Millisecond, now.Add(250 * time.Millisecond).UnixNano()},
+		{36 * time.Hour, now.Add(36 * time.Hour).UnixNano()},
+	}
+
+	for _, test := range tests {
+		if got := ExpiresAt(test.ttl); got != test.want {
+			t.Errorf("ExpiresAt(%s) = %d, want %d", test.ttl, got, test.want)
+		}
+	}
+}
+
+func TestItemExpired(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	Now = func() time.Time { return now }
+	defer func() { Now = time.Now }()
+
+	tests := []time.Duration{
+		time.Nanosecond,
+		10 * time.Millisecond,
+		500 * time.Millisecond,
+		time.Hour,
+		72 * time.Hour,
+	}
+
+	for _, ttl := range tests {
+		now = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		i := Item{Expiration: ExpiresAt(ttl)}
+
+		if i.Expired() {
+			t.Errorf("Expected item with ttl %s to not be expired", ttl)
+		}
+
+		now = now.Add(ttl - 1)
+		if ttl > time.Nanosecond && i.Expired() {
+			t.Errorf("Expected item with ttl %s to not be expired before the ttl", ttl)
+		}
+
+		now = now.Add(1)
+		if !i.Expired() {
+			t.Errorf("Expected item with ttl %s to be expired", ttl)
+		}
+	}
+
+	if (Item{}).Expired() {
+		t.Error("Expected item without expiration to not be expired")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	Now = func() time.Time { return now }
+	defer func() { Now = time.Now }()
+
+	tests := []struct {
+		expiration int64
+		want       time.Duration
+	}{
+		{0, 0},
+		{now.Add(-time.Second).UnixNano(), 0},
+		{now.Add(500 * time.Millisecond).UnixNano(), 500 * time.Millisecond},
+		{now.Add(12 * time.Hour).UnixNano(), 12 * time.Hour},
+	}
+
+	for _, test := range tests {
+		if got := TTL(test.expiration); got != test.want {
+			t.Errorf("TTL(%d) = %s, want %s", test.expiration, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package tiered
+
+import "time"
+
+// Option configures a tiered store.
+type Option func(*Store)
+
+// WithUpperTTL sets the max expiration of items in all tiers but the
+// last one, both when items are stored and when a upper tier is filled
+// with a item that was found in a lower tier. Items that are found in
+// a lower tier without a expiration are stored with this ttl. Items
+// from a lower tier that isn't a store.TTLStore are only stored in
+// the upper tiers when this is set, since their ttl is unknown.
+func WithUpperTTL(ttl time.Duration) Option {
+	return func(s *Store) {
+		s.upperTTL = ttl
+	}
+}
+
+// WithFatalErrors sets if errors from all tiers are returned or
+// only errors from the last tier, errors from upper tiers are
+// then ignored and the next tier is used. Errors are fatal by default.
+func WithFatalErrors(fatal bool) Option {
+	return func(s *Store) {
+		s.fatal = fatal
+	}
+}
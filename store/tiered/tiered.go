@@ -0,0 +1,258 @@
+package tiered
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// Store represents the tiered cache store. Reads are done from the first
+// tier that has the item and the tiers above it are filled with the item,
+// writes and removes are done in all tiers. The last tier is the source
+// of truth and is used for numbers.
+type Store struct {
+	stores   []store.Store
+	upperTTL time.Duration
+	fatal    bool
+}
+
+// NewStore will create a new tiered store with the given stores, the
+// first store is the upper tier, e.g. a memory store in front of a
+// redis store. NewStore panics if no stores are given.
+func NewStore(stores []store.Store, opts ...Option) store.Store {
+	if len(stores) == 0 {
+		panic("tiered: no stores")
+	}
+
+	s := &Store{
+		stores: stores,
+		fatal:  true,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// last returns the index of the last tier.
+func (s *Store) last() int {
+	return len(s.stores) - 1
+}
+
+// ignore returns true if the error from the tier should be ignored.
+func (s *Store) ignore(tier int, err error) bool {
+	return err == nil || (!s.fatal && tier != s.last())
+}
+
+// ttl returns the expiration to use for the tier.
+func (s *Store) ttl(tier int, expiration time.Duration) time.Duration {
+	if tier == s.last() || s.upperTTL <= 0 {
+		return expiration
+	}
+
+	if expiration <= 0 || expiration > s.upperTTL {
+		return s.upperTTL
+	}
+
+	return expiration
+}
+
+// backfill stores the value found in the given tier in the tiers above
+// it, with the remaining ttl of the item. The tiers are not filled if the
+// ttl can't be found and no upper ttl is set, since the item would never
+// expire in them. Errors are ignored since the value has been found.
+func (s *Store) backfill(tier int, key string, value interface{}) {
+	if tier == 0 {
+		return
+	}
+
+	expiration := s.upperTTL
+
+	if ts, ok := s.stores[tier].(store.TTLStore); ok {
+		ttl, err := ts.TTL(key)
+		if err != nil {
+			return
+		}
+
+		expiration = ttl
+	} else if s.upperTTL <= 0 {
+		return
+	}
+
+	for i := tier - 1; i >= 0; i-- {
+		s.stores[i].Set(key, value, s.ttl(i, expiration))
+	}
+}
+
+// invalidate removes the key from all tiers but the last one.
+func (s *Store) invalidate(key string) error {
+	for i := s.last() - 1; i >= 0; i-- {
+		err := s.stores[i].Remove(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+
+		if !s.ignore(i, err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close will close all tiers and returns the first error.
+func (s *Store) Close() error {
+	var first error
+
+	for _, st := range s.stores {
+		if err := st.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Decrement will decrement a number in the last tier and
+// remove the key from the upper tiers.
+func (s *Store) Decrement(key string, n ...int64) (int64, error) {
+	v, err := s.stores[s.last()].Decrement(key, n...)
+	if err != nil {
+		return 0, err
+	}
+
+	return v, s.invalidate(key)
+}
+
+// Flush will remove all items from all tiers.
+func (s *Store) Flush() error {
+	for i := s.last(); i >= 0; i-- {
+		if err := s.stores[i].Flush(); !s.ignore(i, err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FlushPrefix will remove all items with keys that starts with the prefix
+// from all tiers, all tiers has to implement store.PrefixStore.
+func (s *Store) FlushPrefix(prefix string) error {
+	for i := s.last(); i >= 0; i-- {
+		ps, ok := s.stores[i].(store.PrefixStore)
+		if !ok {
+			return store.ErrNotSupported
+		}
+
+		if err := ps.FlushPrefix(prefix); !s.ignore(i, err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get will retrieve a item from the first tier that has it.
+func (s *Store) Get(key string) (interface{}, error) {
+	for i, st := range s.stores {
+		v, err := st.Get(key)
+		if err == nil {
+			s.backfill(i, key, v)
+			return v, nil
+		}
+
+		if errors.Is(err, store.ErrNotFound) && i != s.last() {
+			continue
+		}
+
+		if !s.ignore(i, err) {
+			return nil, err
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+// Increment will increment a number in the last tier and
+// remove the key from the upper tiers.
+func (s *Store) Increment(key string, n ...int64) (int64, error) {
+	v, err := s.stores[s.last()].Increment(key, n...)
+	if err != nil {
+		return 0, err
+	}
+
+	return v, s.invalidate(key)
+}
+
+// Number will retrieve a number from the last tier.
+func (s *Store) Number(key string) (int64, error) {
+	return s.stores[s.last()].Number(key)
+}
+
+// Remove will remove a item from all tiers, store.ErrNotFound
+// is returned if no tier has the item.
+func (s *Store) Remove(key string) error {
+	found := false
+
+	for i := s.last(); i >= 0; i-- {
+		err := s.stores[i].Remove(key)
+		if err == nil {
+			found = true
+			continue
+		}
+
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+
+		if !s.ignore(i, err) {
+			return err
+		}
+	}
+
+	if !found {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// Result will retrieve a item from the first tier that has it and stores
+// the result in the value pointed to by value.
+func (s *Store) Result(key string, value interface{}) error {
+	for i, st := range s.stores {
+		err := st.Result(key, value)
+		if err == nil {
+			if i > 0 {
+				s.backfill(i, key, reflect.ValueOf(value).Elem().Interface())
+			}
+
+			return nil
+		}
+
+		if errors.Is(err, store.ErrNotFound) && i != s.last() {
+			continue
+		}
+
+		if !s.ignore(i, err) {
+			return err
+		}
+	}
+
+	return store.ErrNotFound
+}
+
+// Set will store a item in all tiers, starting with the last tier.
+func (s *Store) Set(key string, value interface{}, expiration time.Duration) error {
+	for i := s.last(); i >= 0; i-- {
+		if err := s.stores[i].Set(key, value, s.ttl(i, expiration)); !s.ignore(i, err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package tiered
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+	"github.com/frozzare/go-cache/store/memory"
+)
+
+// failingStore is a store where all calls fails.
+type failingStore struct {
+	store.Store
+}
+
+var errFailed = errors.New("failed")
+
+func (failingStore) Get(string) (interface{}, error)              { return nil, errFailed }
+func (failingStore) Remove(string) error                          { return errFailed }
+func (failingStore) Result(string, interface{}) error             { return errFailed }
+func (failingStore) Set(string, interface{}, time.Duration) error { return errFailed }
+
+// missingStore is a store that never has the item and can't store it.
+type missingStore struct {
+	store.Store
+}
+
+func (missingStore) Get(string) (interface{}, error)              { return nil, store.ErrNotFound }
+func (missingStore) Result(string, interface{}) error             { return store.ErrNotFound }
+func (missingStore) Set(string, interface{}, time.Duration) error { return errFailed }
+
+// noTTLStore hides the ttl methods of the store.
+type noTTLStore struct {
+	store.Store
+}
+
+func TestStore(t *testing.T) {
+	l1 := memory.NewStore()
+	l2 := memory.NewStore()
+	c := NewStore([]store.Store{l1, l2})
+
+	defer c.Close()
+
+	if err := c.Set("name", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []store.Store{l1, l2} {
+		if v, err := s.Get("name"); err != nil || v != "go" {
+			t.Fatalf("Expected go in all tiers, got %v (%v)", v, err)
+		}
+	}
+
+	if err := c.Remove("name"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []store.Store{l1, l2} {
+		if _, err := s.Get("name"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected store.ErrNotFound, got %v", err)
+		}
+	}
+
+	if err := c.Remove("name"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewStoreEmpty(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected NewStore to panic without stores")
+		}
+	}()
+
+	NewStore(nil)
+}
+
+func TestStoreBackfill(t *testing.T) {
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	defer func() { store.Now = time.Now }()
+
+	l1 := memory.NewStore()
+	l2 := memory.NewStore()
+	c := NewStore([]store.Store{l1, l2}, WithUpperTTL(time.Minute))
+
+	defer c.Close()
+
+	if err := l2.Set("name", "go", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	if v, err := l1.Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go to be backfilled, got %v (%v)", v, err)
+	}
+
+	if ttl, err := l1.(store.TTLStore).TTL("name"); err != nil || ttl != time.Minute {
+		t.Fatalf("Expected ttl of a minute, got %s (%v)", ttl, err)
+	}
+
+	now = now.Add(time.Minute)
+
+	if _, err := l1.Get("name"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+
+	var v string
+	if err := c.Result("name", &v); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	if v, err := l1.Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go to be backfilled, got %v (%v)", v, err)
+	}
+
+	if _, err := c.Get("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreBackfillUnknownTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		backfill bool
+	}{
+		{"NoUpperTTL", nil, false},
+		{"UpperTTL", []Option{WithUpperTTL(time.Minute)}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l1 := memory.NewStore()
+			l2 := memory.NewStore()
+			c := NewStore([]store.Store{l1, noTTLStore{l2}}, test.opts...)
+
+			defer c.Close()
+
+			if err := l2.Set("name", "go", time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if v, err := c.Get("name"); err != nil || v != "go" {
+				t.Fatalf("Expected go, got %v (%v)", v, err)
+			}
+
+			if _, err := l1.Get("name"); (err == nil) != test.backfill {
+				t.Fatalf("Expected backfill to be %v, got %v", test.backfill, err)
+			}
+		})
+	}
+}
+
+func TestStoreBackfillError(t *testing.T) {
+	l2 := memory.NewStore()
+	c := NewStore([]store.Store{missingStore{}, l2})
+
+	if err := l2.Set("name", "go", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Get("name"); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+
+	var v string
+	if err := c.Result("name", &v); err != nil || v != "go" {
+		t.Fatalf("Expected go, got %v (%v)", v, err)
+	}
+}
+
+func TestStoreNumber(t *testing.T) {
+	l1 := memory.NewStore()
+	l2 := memory.NewStore()
+	c := NewStore([]store.Store{l1, l2})
+
+	defer c.Close()
+
+	if err := c.Set("number", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Increment("number", 2); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+
+	if _, err := l1.Get("number"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected number to be removed from upper tier, got %v", err)
+	}
+
+	if v, err := c.Number("number"); err != nil || v != 3 {
+		t.Fatalf("Expected 3, got %d (%v)", v, err)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	tests := []struct {
+		fatal bool
+		err   error
+	}{
+		{true, errFailed},
+		{false, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("fatal=%v", test.fatal), func(t *testing.T) {
+			l2 := memory.NewStore()
+			c := NewStore([]store.Store{failingStore{}, l2}, WithFatalErrors(test.fatal))
+
+			if err := c.Set("name", "go", 0); !errors.Is(err, test.err) {
+				t.Fatalf("Expected %v, got %v", test.err, err)
+			}
+
+			if err := l2.Set("name", "go", 0); err != nil {
+				t.Fatal(err)
+			}
+
+			v, err := c.Get("name")
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected %v, got %v", test.err, err)
+			}
+
+			if err == nil && v != "go" {
+				t.Fatalf("Expected go, got %v", v)
+			}
+		})
+	}
+}
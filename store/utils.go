@@ -1,41 +1,94 @@
 package store
 
 import (
-	"bytes"
-	"encoding/gob"
+	"fmt"
 	"reflect"
-
-	"github.com/pquerna/ffjson/ffjson"
 )
 
-func isJSON(s string) bool {
-	return (s[0] == '{' && s[len(s)-1] == '}') || (s[0] == '[' || s[len(s)-1] == ']')
-}
-
-// Marshal value to bytes using gob or ffjson.
+// Marshal value to bytes using the default codec for the value.
 func Marshal(value interface{}) ([]byte, error) {
-	switch reflect.ValueOf(value).Kind() {
-	case reflect.Ptr, reflect.Struct, reflect.Map:
-		return ffjson.Marshal(value)
-	default:
-		b := &bytes.Buffer{}
-		err := gob.NewEncoder(b).Encode(&Item{
-			Object: value,
-		})
+	return MarshalWith(nil, value)
+}
 
-		if err != nil {
-			return nil, err
-		}
+// MarshalWith will marshal value to bytes using the given codec,
+// the default codec for the value is used if the codec is nil.
+// The returned bytes starts with a header that tells which codec
+// that was used so Unmarshal never has to guess.
+func MarshalWith(c Codec, value interface{}) ([]byte, error) {
+	if c == nil {
+		c = DefaultCodec(value)
+	}
 
-		return b.Bytes(), nil
+	buf, err := c.Marshal(value)
+	if err != nil {
+		return nil, err
 	}
+
+	return append([]byte{magic, c.ID()}, buf...), nil
 }
 
-// Unmarshal bytes with gob or ffjson.
+// Unmarshal bytes with the codec that is written in the header.
+// Payloads without a header are decoded with unmarshalLegacy.
 func Unmarshal(buf []byte, value interface{}) error {
-	if isJSON(string(buf)) {
-		return ffjson.Unmarshal(buf, value)
+	if len(buf) > 0 && buf[0] != magic {
+		return unmarshalLegacy(buf, value)
 	}
 
-	return gob.NewDecoder(bytes.NewBuffer(buf)).Decode(value)
+	if len(buf) < 2 {
+		return ErrInvalidPayload
+	}
+
+	codecsMu.RLock()
+	c, ok := codecs[buf[1]]
+	codecsMu.RUnlock()
+
+	if !ok {
+		return ErrInvalidPayload
+	}
+
+	return c.Unmarshal(buf[2:], value)
+}
+
+// unmarshalLegacy decodes a payload that was written before payloads
+// had a codec header. Such payloads are JSON objects and arrays or gob
+// encoded items, a gob encoded item never starts with { or [.
+func unmarshalLegacy(buf []byte, value interface{}) error {
+	var err error
+
+	switch last := buf[len(buf)-1]; {
+	case buf[0] == '{' && last == '}', buf[0] == '[' && last == ']':
+		err = JSONCodec.Unmarshal(buf, value)
+	default:
+		err = GobCodec.Unmarshal(buf, value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	return nil
+}
+
+// Delta returns the first value of n or one if n is empty,
+// it's used by the increment and decrement methods.
+func Delta(n ...int64) int64 {
+	if len(n) > 0 {
+		return n[0]
+	}
+
+	return 1
+}
+
+// Int64 converts a integer value to int64.
+func Int64(value interface{}) (int64, error) {
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
 }
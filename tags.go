@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+)
+
+// TaggedCache represents a cache where items are stored under a set of
+// tags. Each tag has a version number that is part of the item keys, so
+// flushing the tags makes all items stored with them unreachable. The
+// items are not removed from the store and will stay until they expire.
+type TaggedCache struct {
+	cache *Cache
+	tags  []string
+}
+
+// Tags returns a tagged cache for the given tags.
+func (c *Cache) Tags(tags ...string) *TaggedCache {
+	return &TaggedCache{
+		cache: c,
+		tags:  tags,
+	}
+}
+
+// tagKey returns the key that holds the version of the tag.
+func tagKey(tag string) string {
+	return "tag:" + tag + ":version"
+}
+
+// version returns the version of the tag, a tag without a version
+// gets the current time as version so it won't match a version that
+// was used before the tag was evicted from the store. The version is
+// added with Add so concurrent callers agree on it. Stores that are not
+// a store.ConditionalStore uses Increment instead and the version is read
+// again, so callers that has incremented it at the same time agree on it
+// unless one of them reads it before the other has incremented it.
+func (t *TaggedCache) version(tag string) (int64, error) {
+	key := tagKey(tag)
+
+	v, err := t.cache.Number(key)
+	if !IsNotFound(err) {
+		return v, err
+	}
+
+	now := time.Now().UnixNano()
+
+	_, err = t.cache.Add(key, now)
+	if errors.Is(err, store.ErrNotSupported) {
+		_, err = t.cache.Increment(key, now)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return t.cache.Number(key)
+}
+
+// key returns the key of the item based on the tags versions.
+func (t *TaggedCache) key(key string) (string, error) {
+	versions := make([]string, len(t.tags))
+
+	for i, tag := range t.tags {
+		v, err := t.version(tag)
+		if err != nil {
+			return "", err
+		}
+
+		versions[i] = tag + ":" + strconv.FormatInt(v, 10)
+	}
+
+	h := sha1.Sum([]byte(strings.Join(versions, "|")))
+
+	return "tags:" + hex.EncodeToString(h[:]) + ":" + key, nil
+}
+
+// Flush will invalidate all items stored with any of the tags.
+func (t *TaggedCache) Flush() error {
+	for _, tag := range t.tags {
+		if _, err := t.version(tag); err != nil {
+			return err
+		}
+
+		if _, err := t.cache.Increment(tagKey(tag)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get will retrieve a item from the cache.
+func (t *TaggedCache) Get(key string) (interface{}, error) {
+	k, err := t.key(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.cache.Get(k)
+}
+
+// Remember will retrieve a item from the cache, if the item don't exists
+// fn is called and the returned value is stored in the cache.
+func (t *TaggedCache) Remember(key string, expiration time.Duration, fn store.RememberFunc) (interface{}, error) {
+	k, err := t.key(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.cache.Remember(k, expiration, fn)
+}
+
+// Remove will remove a item from the cache.
+func (t *TaggedCache) Remove(key string) error {
+	k, err := t.key(key)
+	if err != nil {
+		return err
+	}
+
+	return t.cache.Remove(k)
+}
+
+// Result will retrieve a item from the cache and stores the
+// result in the value pointed to by v.
+func (t *TaggedCache) Result(key string, v interface{}) error {
+	k, err := t.key(key)
+	if err != nil {
+		return err
+	}
+
+	return t.cache.Result(k, v)
+}
+
+// Set will store a item in the cache.
+func (t *TaggedCache) Set(key string, value interface{}, expiration ...time.Duration) error {
+	k, err := t.key(key)
+	if err != nil {
+		return err
+	}
+
+	return t.cache.Set(k, value, expiration...)
+}
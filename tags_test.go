@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/frozzare/go-cache/store"
+	"github.com/frozzare/go-cache/store/memory"
+)
+
+func TestTags(t *testing.T) {
+	c := New(memory.NewStore())
+
+	if err := c.Tags("customer:1", "orders").Set("order:1", "one"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Tags("customer:2", "orders").Set("order:2", "two"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Tags("customer:1", "orders").Get("order:1"); err != nil || v != "one" {
+		t.Fatalf("Expected one, got %v (%v)", v, err)
+	}
+
+	if _, err := c.Get("order:1"); !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+
+	if err := c.Tags("customer:1").Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Tags("customer:1", "orders").Get("order:1"); !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+
+	if v, err := c.Tags("customer:2", "orders").Get("order:2"); err != nil || v != "two" {
+		t.Fatalf("Expected two, got %v (%v)", v, err)
+	}
+
+	if err := c.Tags("orders").Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Tags("customer:2", "orders").Get("order:2"); !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+}
+
+func TestTagsEvicted(t *testing.T) {
+	c := New(memory.NewStore())
+
+	if err := c.Tags("customer:1").Set("name", "go"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Remove(tagKey("customer:1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Tags("customer:1").Get("name"); !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+}
+
+// missStore is a store where the first two calls to Number waits for
+// each other after reading, so both callers miss the tag version, and
+// the next two waits for each other before reading, so both callers
+// has written the version before they read it again.
+type missStore struct {
+	store.Store
+	calls   int32
+	missed  sync.WaitGroup
+	written sync.WaitGroup
+}
+
+func newMissStore() *missStore {
+	s := &missStore{Store: memory.NewStore()}
+	s.missed.Add(2)
+	s.written.Add(2)
+	return s
+}
+
+// meet waits for the other caller, or for a second
+// so the test fails instead of hanging if it never comes.
+func meet(wg *sync.WaitGroup) {
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	wg.Done()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+	}
+}
+
+func (s *missStore) Number(key string) (int64, error) {
+	n := atomic.AddInt32(&s.calls, 1)
+
+	if n == 3 || n == 4 {
+		meet(&s.written)
+	}
+
+	v, err := s.Store.Number(key)
+
+	if n <= 2 {
+		meet(&s.missed)
+	}
+
+	return v, err
+}
+
+// conditionalMissStore is a missStore that implements store.ConditionalStore.
+type conditionalMissStore struct {
+	*missStore
+	store.ConditionalStore
+}
+
+func TestTagsConcurrent(t *testing.T) {
+	tests := map[string]func() store.Store{
+		"Add": func() store.Store {
+			s := newMissStore()
+			return conditionalMissStore{s, s.Store.(store.ConditionalStore)}
+		},
+		"Increment": func() store.Store {
+			return newMissStore()
+		},
+	}
+
+	for name, newStore := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := New(newStore())
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					if err := c.Tags("orders").Set(fmt.Sprintf("order:%d", i), i); err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+
+			wg.Wait()
+
+			for i := 0; i < 2; i++ {
+				if v, err := c.Tags("orders").Get(fmt.Sprintf("order:%d", i)); err != nil || v != i {
+					t.Fatalf("Expected %d, got %v (%v)", i, v, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import "time"
+
+// Typed wraps a cache and retrieves items as values of type T. Values are
+// decoded with Result so the same concrete type is returned from every
+// store, numbers are converted to T when a store decodes them as another
+// number type.
+type Typed[T any] struct {
+	cache *Cache
+}
+
+// NewTyped will create a new typed cache on top of the given cache.
+func NewTyped[T any](c *Cache) *Typed[T] {
+	return &Typed[T]{c}
+}
+
+// Cache returns the underlying cache.
+func (t *Typed[T]) Cache() *Cache {
+	return t.cache
+}
+
+// Get will retrieve a item from the cache.
+func (t *Typed[T]) Get(key string) (T, error) {
+	var v T
+	err := t.cache.Result(key, &v)
+	return v, err
+}
+
+// Remember will retrieve a item from the cache, if the item don't exists
+// fn is called and the returned value is stored in the cache with the
+// given expiration. Concurrent calls for the same key will wait for the
+// first call to fn and share its result.
+func (t *Typed[T]) Remember(key string, expiration time.Duration, fn func() (T, error)) (T, error) {
+	v, err := t.Get(key)
+	if err == nil || !IsNotFound(err) {
+		return v, err
+	}
+
+	r, err := t.cache.Remember(key, expiration, func() (interface{}, error) {
+		return fn()
+	})
+	if err != nil {
+		return v, err
+	}
+
+	if v, ok := r.(T); ok {
+		return v, nil
+	}
+
+	// The item was stored by someone else between the calls,
+	// so it has to be decoded to T.
+	return t.Get(key)
+}
+
+// Set will store a item in the cache.
+func (t *Typed[T]) Set(key string, value T, expiration ...time.Duration) error {
+	return t.cache.Set(key, value, expiration...)
+}
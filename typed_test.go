@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+func testTyped[T any](t *testing.T, c *Cache, value T) {
+	tc := NewTyped[T](c)
+
+	if err := tc.Set("typed", value); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := tc.Get("typed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, value) {
+		t.Fatalf("%v (%T) does not match the expected value: %v (%T)", v, v, value, value)
+	}
+}
+
+func TestTyped(t *testing.T) {
+	for name, c := range caches(t) {
+		t.Run(name, func(t *testing.T) {
+			testTyped(t, c, "go")
+			testTyped(t, c, 1)
+			testTyped(t, c, int64(1))
+			testTyped(t, c, 1.2)
+			testTyped(t, c, []string{"abc"})
+			testTyped(t, c, map[string]int{"a": 1})
+			testTyped(t, c, &user{Name: "go"})
+			testTyped(t, c, user{Name: "go"})
+		})
+	}
+}
+
+func TestTypedConvert(t *testing.T) {
+	for name, c := range caches(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := c.Set("number", 42); err != nil {
+				t.Fatal(err)
+			}
+
+			v, err := NewTyped[int64](c).Get("number")
+			if err != nil || v != 42 {
+				t.Fatalf("Expected 42, got %v (%v)", v, err)
+			}
+
+			if _, err := NewTyped[string](c).Get("number"); err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+
+			if _, err := NewTyped[int](c).Get("missing"); !IsNotFound(err) {
+				t.Fatalf("Expected not found error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTypedRemember(t *testing.T) {
+	for name, c := range caches(t) {
+		t.Run(name, func(t *testing.T) {
+			tc := NewTyped[*user](c)
+
+			for i := 0; i < 2; i++ {
+				v, err := tc.Remember("user", 0, func() (*user, error) {
+					if i > 0 {
+						t.Fatal("Expected remember func to only be called once")
+					}
+
+					return &user{Name: "go"}, nil
+				})
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if v.Name != "go" {
+					t.Fatalf("%s does not match the expected value: go", v.Name)
+				}
+			}
+		})
+	}
+}